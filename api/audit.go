@@ -0,0 +1,117 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"integ/entities"
+	"integ/service"
+	"integ/storage"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	requestIDHeader = "X-Request-ID"
+	// maxRequestIDLength более длинные идентификаторы от клиента заменяются сгенерированными
+	maxRequestIDLength = 128
+)
+
+// RequestID берет идентификатор запроса из X-Request-ID или генерирует новый,
+// возвращает его в ответе и передает в контекст для журнала аудита
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(requestIDHeader)
+		if requestID == "" || len(requestID) > maxRequestIDLength {
+			requestID = newRequestID()
+		}
+
+		c.Header(requestIDHeader, requestID)
+		c.Request = c.Request.WithContext(service.WithRequestID(c.Request.Context(), requestID))
+		c.Next()
+	}
+}
+
+func newRequestID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(id)
+}
+
+// AuditEvents журнал аудита для администраторов: from и to в RFC 3339,
+// action, actor_user_id, actor_subject, target_user_id, cursor и limit
+func (h *HTTPHandler) AuditEvents(c *gin.Context) {
+	query, err := parseAuditQuery(c)
+	if err != nil {
+		abortWithProblem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	page, err := h.svc.FindAuditEvents(c.Request.Context(), query)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+func parseAuditQuery(c *gin.Context) (storage.AuditQuery, error) {
+	var query storage.AuditQuery
+
+	limit, err := strconv.ParseUint(c.DefaultQuery("limit", strconv.Itoa(defaultLimit)), 10, 32)
+	if err != nil {
+		return query, err
+	}
+	if limit == 0 || limit > maxLimit {
+		return query, fmt.Errorf("limit must be in range 1..%d", maxLimit)
+	}
+	query.Limit = uint(limit)
+
+	if from := c.Query("from"); from != "" {
+		if query.From, err = time.Parse(time.RFC3339, from); err != nil {
+			return query, err
+		}
+	}
+	if to := c.Query("to"); to != "" {
+		if query.To, err = time.Parse(time.RFC3339, to); err != nil {
+			return query, err
+		}
+	}
+	if !query.From.IsZero() && !query.To.IsZero() && !query.From.Before(query.To) {
+		return query, fmt.Errorf("from must be before to")
+	}
+
+	switch action := entities.AuditAction(c.Query("action")); action {
+	case "", entities.AuditSaveContacts, entities.AuditSaveHashedContacts,
+		entities.AuditSyncContacts, entities.AuditSyncContactsDelta,
+		entities.AuditFindFriends, entities.AuditGetName:
+		query.Action = action
+	default:
+		return query, fmt.Errorf("unknown action %q", action)
+	}
+
+	if actor := c.Query("actor_user_id"); actor != "" {
+		if query.ActorUserID, err = strconv.Atoi(actor); err != nil {
+			return query, err
+		}
+	}
+	query.ActorSubject = c.Query("actor_subject")
+	if target := c.Query("target_user_id"); target != "" {
+		if query.TargetUserID, err = strconv.Atoi(target); err != nil {
+			return query, err
+		}
+	}
+	if cursor := c.Query("cursor"); cursor != "" {
+		if query.Cursor, err = strconv.ParseInt(cursor, 10, 64); err != nil {
+			return query, err
+		}
+	}
+
+	return query, nil
+}
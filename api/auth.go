@@ -0,0 +1,102 @@
+package api
+
+import (
+	"integ/auth"
+	"integ/service"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const claimsKey = "auth.claims"
+
+type TokenParser interface {
+	Parse(token string) (*auth.Claims, error)
+}
+
+// Authenticate проверяет bearer токен и сохраняет его утверждения в контексте запроса
+func Authenticate(parser TokenParser) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		token := strings.TrimPrefix(header, "Bearer ")
+		if header == "" || token == header {
+			abortUnauthorized(c, "bearer token is required")
+			return
+		}
+
+		claims, err := parser.Parse(token)
+		if err != nil {
+			abortUnauthorized(c, err.Error())
+			return
+		}
+
+		c.Set(claimsKey, claims)
+		c.Request = c.Request.WithContext(service.WithActor(c.Request.Context(), claims.Subject, claims.Role))
+		c.Next()
+	}
+}
+
+// RequireUser разрешает доступ только владельцу данных из параметра пути param
+// или администратору, должен вызываться после Authenticate
+func RequireUser(param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := ClaimsFrom(c)
+		if !ok {
+			abortUnauthorized(c, "bearer token is required")
+			return
+		}
+		if claims.IsAdmin() {
+			c.Next()
+			return
+		}
+
+		userID, err := strconv.Atoi(c.Param(param))
+		if err != nil {
+			abortWithProblem(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		subject, err := claims.UserID()
+		if err != nil || subject != userID {
+			abortWithProblem(c, http.StatusForbidden, "access to another user's data is not allowed")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireAdmin разрешает доступ только администратору, должен вызываться после Authenticate
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := ClaimsFrom(c)
+		if !ok {
+			abortUnauthorized(c, "bearer token is required")
+			return
+		}
+		if !claims.IsAdmin() {
+			abortWithProblem(c, http.StatusForbidden, "admin role is required")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// ClaimsFrom утверждения токена, сохраненные Authenticate
+func ClaimsFrom(c *gin.Context) (*auth.Claims, bool) {
+	value, ok := c.Get(claimsKey)
+	if !ok {
+		return nil, false
+	}
+
+	claims, ok := value.(*auth.Claims)
+	return claims, ok
+}
+
+func abortUnauthorized(c *gin.Context, detail string) {
+	c.Header("WWW-Authenticate", `Bearer realm="integ"`)
+	abortWithProblem(c, http.StatusUnauthorized, detail)
+}
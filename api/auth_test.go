@@ -0,0 +1,100 @@
+package api
+
+import (
+	"integ/auth"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testSecret = "secret"
+
+func signToken(t *testing.T, subject, role string, expiresAt time.Time) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, auth.Claims{
+		Role: role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   subject,
+			Issuer:    "integ",
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	})
+	signed, err := token.SignedString([]byte(testSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+// newAuthRouter маршруты с теми же группами доступа, что и в main, обработчики отвечают 200
+func newAuthRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	authenticator, err := auth.NewAuthenticator("integ", map[string]string{"k1": testSecret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticate := Authenticate(authenticator)
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+
+	router := gin.New()
+	router.GET("/user/:userID", authenticate, RequireUser("userID"), ok)
+	router.GET("/admin/audit", authenticate, RequireAdmin(), ok)
+	router.GET("/debug/db/pool", authenticate, RequireAdmin(), ok)
+
+	return router
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	router := newAuthRouter(t)
+
+	valid := time.Now().Add(time.Hour)
+	user := signToken(t, "42", "", valid)
+	admin := signToken(t, "ops@example.com", auth.RoleAdmin, valid)
+
+	tests := []struct {
+		name   string
+		path   string
+		header string
+		status int
+	}{
+		{name: "без токена", path: "/user/42", status: http.StatusUnauthorized},
+		{name: "не bearer", path: "/user/42", header: "Basic " + user, status: http.StatusUnauthorized},
+		{name: "поврежденный токен", path: "/user/42", header: "Bearer " + user[:len(user)-4], status: http.StatusUnauthorized},
+		{name: "не токен", path: "/user/42", header: "Bearer not-a-token", status: http.StatusUnauthorized},
+		{name: "истекший токен", path: "/user/42", header: "Bearer " + signToken(t, "42", "", time.Now().Add(-time.Hour)), status: http.StatusUnauthorized},
+		{name: "свои данные", path: "/user/42", header: "Bearer " + user, status: http.StatusOK},
+		{name: "чужие данные", path: "/user/43", header: "Bearer " + user, status: http.StatusForbidden},
+		{name: "некорректный идентификатор", path: "/user/abc", header: "Bearer " + user, status: http.StatusBadRequest},
+		{name: "администратор к данным пользователя", path: "/user/43", header: "Bearer " + admin, status: http.StatusOK},
+		{name: "пользователь к журналу аудита", path: "/admin/audit", header: "Bearer " + user, status: http.StatusForbidden},
+		{name: "администратор к журналу аудита", path: "/admin/audit", header: "Bearer " + admin, status: http.StatusOK},
+		{name: "аноним к debug", path: "/debug/db/pool", status: http.StatusUnauthorized},
+		{name: "пользователь к debug", path: "/debug/db/pool", header: "Bearer " + user, status: http.StatusForbidden},
+		{name: "администратор к debug", path: "/debug/db/pool", header: "Bearer " + admin, status: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("ожидался статус %d, получен %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+			if rec.Code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Fatal("ответ 401 должен содержать WWW-Authenticate")
+			}
+		})
+	}
+}
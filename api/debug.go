@@ -0,0 +1,32 @@
+package api
+
+import (
+	"integ/storage"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PoolStater interface {
+	PoolStat() (*storage.PoolStat, bool)
+}
+
+type DebugHandler struct {
+	pool PoolStater
+}
+
+func NewDebugHandler(pool PoolStater) *DebugHandler {
+	return &DebugHandler{
+		pool: pool,
+	}
+}
+
+func (h *DebugHandler) PoolStat(c *gin.Context) {
+	stat, ok := h.pool.PoolStat()
+	if !ok {
+		abortWithProblem(c, http.StatusNotFound, "database pool statistics are not available")
+		return
+	}
+
+	c.JSON(http.StatusOK, stat)
+}
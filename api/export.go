@@ -0,0 +1,88 @@
+package api
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"integ/entities"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	exportFormatJSON = "json"
+	exportFormatZIP  = "zip"
+)
+
+// ExportUser выгрузка всех данных пользователя, format=zip отдает архив с разделами в отдельных файлах
+func (h *HTTPHandler) ExportUser(c *gin.Context) {
+	userIDstr := c.Param("userID")
+	userID, err := strconv.Atoi(userIDstr)
+	if err != nil {
+		abortWithProblem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	format := c.DefaultQuery("format", exportFormatJSON)
+	if format != exportFormatJSON && format != exportFormatZIP {
+		abortWithProblem(c, http.StatusBadRequest, fmt.Sprintf("unknown format %q", format))
+		return
+	}
+
+	export, err := h.svc.ExportUser(c.Request.Context(), userID)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	filename := fmt.Sprintf("user-%d-export.%s", userID, format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	if format == exportFormatJSON {
+		c.JSON(http.StatusOK, export)
+		return
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Status(http.StatusOK)
+	if err := writeExportZIP(c.Writer, export); err != nil {
+		// заголовки уже отправлены, остается только записать ошибку в лог
+		h.log.WithError(err).Errorln("Failed to write user export archive")
+	}
+}
+
+func writeExportZIP(w http.ResponseWriter, export *entities.UserExport) error {
+	archive := zip.NewWriter(w)
+
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"user.json", export.User},
+		{"relations.json", export.Relations},
+		{"incoming_relations.json", export.IncomingRelations},
+		{"pending_contacts.json", export.PendingContacts},
+		{"blocks.json", export.Blocks},
+		{"audit_events.json", export.AuditEvents},
+	}
+	for _, file := range files {
+		f, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     file.name,
+			Method:   zip.Deflate,
+			Modified: export.ExportedAt,
+		})
+		if err != nil {
+			return err
+		}
+
+		encoder := json.NewEncoder(f)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.data); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
@@ -2,32 +2,79 @@ package api
 
 import (
 	"context"
+	"fmt"
 	"integ/entities"
+	"integ/storage"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 )
 
 type RelationService interface {
-	SaveContacts(ctx context.Context, userID int, contacts entities.ContactList) error
-	FindFriends(ctx context.Context, userID int) (entities.FriendsList, error)
+	SaveContacts(ctx context.Context, userID int, contacts entities.ContactList) (*entities.SaveContactsResult, error)
+	SaveHashedContacts(ctx context.Context, userID int, contacts entities.HashedContactList) (*entities.SaveContactsResult, error)
+	DeleteContact(ctx context.Context, userID, relationUserID int) error
+	DeleteContacts(ctx context.Context, userID int, relationUserIDs []int) (int, error)
+	SyncContacts(ctx context.Context, userID int, contacts entities.ContactList) (*entities.SyncContactsResult, error)
+	SyncContactsDelta(ctx context.Context, userID int, delta entities.ContactsDelta) (*entities.SyncContactsResult, error)
+	FindFriends(ctx context.Context, userID int, query storage.FriendsQuery) (*entities.FriendsPage, error)
+	FindMutualFriends(ctx context.Context, userID int) (entities.FriendsList, error)
+	FindCommonFriends(ctx context.Context, userID, otherUserID int) (entities.FriendsList, error)
+	FindFollowers(ctx context.Context, userID int) (entities.FollowerList, error)
+	FindSuggestions(ctx context.Context, userID int, limit, offset uint) (entities.SuggestionList, error)
 	GetName(ctx context.Context, userID int) (string, error)
+	CreateUser(ctx context.Context, user *entities.User) (*entities.User, error)
+	GetUser(ctx context.Context, userID int) (*entities.User, error)
+	UpdateUser(ctx context.Context, userID int, update entities.UserUpdate) (*entities.User, error)
+	DeleteUser(ctx context.Context, userID int) error
+	EraseUser(ctx context.Context, userID int) error
+	ExportUser(ctx context.Context, userID int) (*entities.UserExport, error)
+	FindAuditEvents(ctx context.Context, query storage.AuditQuery) (*entities.AuditPage, error)
+	RequestPhoneVerification(ctx context.Context, userID int) (*entities.PhoneVerificationStarted, error)
+	ConfirmPhoneVerification(ctx context.Context, userID int, code string) (*entities.User, error)
+	GetPrivacy(ctx context.Context, userID int) (*entities.PrivacySettings, error)
+	UpdatePrivacy(ctx context.Context, userID int, settings entities.PrivacySettings) (*entities.PrivacySettings, error)
+	BlockUser(ctx context.Context, userID, blockedUserID int) error
+	UnblockUser(ctx context.Context, userID, blockedUserID int) error
+	FindBlockedUsers(ctx context.Context, userID int) (entities.BlockedUserList, error)
 }
 
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+
+	defaultMaxContacts = 5000
+)
+
 type HTTPHandler struct {
-	svc RelationService
-	log *logrus.Entry
+	svc         RelationService
+	log         *logrus.Entry
+	maxContacts int
 }
 
-func NewHTTPHandler(svc RelationService, log *logrus.Entry) *HTTPHandler {
-	return &HTTPHandler{
-		svc: svc,
-		log: log,
+// WithMaxContacts ограничивает количество контактов в одном запросе загрузки или синхронизации
+func WithMaxContacts(max int) func(*HTTPHandler) {
+	return func(h *HTTPHandler) {
+		h.maxContacts = max
 	}
 }
 
+func NewHTTPHandler(svc RelationService, log *logrus.Entry, opts ...func(*HTTPHandler)) *HTTPHandler {
+	handler := &HTTPHandler{
+		svc:         svc,
+		log:         log,
+		maxContacts: defaultMaxContacts,
+	}
+	for _, opt := range opts {
+		opt(handler)
+	}
+	return handler
+}
+
 type AddContactRequest struct {
 	Contacts entities.ContactList `json:"contacts"`
 }
@@ -36,55 +83,344 @@ func (h *HTTPHandler) AddContacts(c *gin.Context) {
 	userIDstr := c.Param("userID")
 	userID, err := strconv.Atoi(userIDstr)
 	if err != nil {
-		c.AbortWithStatus(http.StatusBadRequest)
+		abortWithProblem(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	var contactRequest AddContactRequest
 
-	if err := c.BindJSON(&contactRequest); err != nil {
-		c.AbortWithStatus(http.StatusBadRequest)
+	if !h.bindContactsJSON(c, &contactRequest) {
+		return
+	}
+	if !h.checkContactsBatch(c, len(contactRequest.Contacts)) {
+		return
+	}
+
+	result, err := h.svc.SaveContacts(c.Request.Context(), userID, contactRequest.Contacts)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+type AddHashedContactsRequest struct {
+	Contacts entities.HashedContactList `json:"contacts"`
+}
+
+func (h *HTTPHandler) AddHashedContacts(c *gin.Context) {
+	userIDstr := c.Param("userID")
+	userID, err := strconv.Atoi(userIDstr)
+	if err != nil {
+		abortWithProblem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var contactRequest AddHashedContactsRequest
+
+	if !h.bindContactsJSON(c, &contactRequest) {
+		return
+	}
+	if !h.checkContactsBatch(c, len(contactRequest.Contacts)) {
+		return
+	}
+
+	result, err := h.svc.SaveHashedContacts(c.Request.Context(), userID, contactRequest.Contacts)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+type DeleteContactsRequest struct {
+	RelationUserIDs []int `json:"relation_user_ids" binding:"required,min=1"`
+}
+
+type DeleteContactsResponse struct {
+	Removed int `json:"removed"`
+}
+
+func (h *HTTPHandler) DeleteContact(c *gin.Context) {
+	userIDstr := c.Param("userID")
+	userID, err := strconv.Atoi(userIDstr)
+	if err != nil {
+		abortWithProblem(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	if err := h.svc.SaveContacts(c.Request.Context(), userID, contactRequest.Contacts); err != nil {
-		c.AbortWithStatus(http.StatusInternalServerError)
+	relationUserIDstr := c.Param("relationUserID")
+	relationUserID, err := strconv.Atoi(relationUserIDstr)
+	if err != nil {
+		abortWithProblem(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	c.Status(http.StatusOK)
+	err = h.svc.DeleteContact(c.Request.Context(), userID, relationUserID)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *HTTPHandler) DeleteContacts(c *gin.Context) {
+	userIDstr := c.Param("userID")
+	userID, err := strconv.Atoi(userIDstr)
+	if err != nil {
+		abortWithProblem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var deleteRequest DeleteContactsRequest
+
+	if !h.bindContactsJSON(c, &deleteRequest) {
+		return
+	}
+
+	removed, err := h.svc.DeleteContacts(c.Request.Context(), userID, deleteRequest.RelationUserIDs)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, DeleteContactsResponse{Removed: removed})
 }
 
 func (h *HTTPHandler) Friends(c *gin.Context) {
 	userIDstr := c.Param("userID")
 	userID, err := strconv.Atoi(userIDstr)
 	if err != nil {
-		c.AbortWithStatus(http.StatusBadRequest)
+		abortWithProblem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	query, err := parseFriendsQuery(c)
+	if err != nil {
+		abortWithProblem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	page, err := h.svc.FindFriends(c.Request.Context(), userID, query)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	c.JSON(200, page)
+}
+
+func (h *HTTPHandler) MutualFriends(c *gin.Context) {
+	userIDstr := c.Param("userID")
+	userID, err := strconv.Atoi(userIDstr)
+	if err != nil {
+		abortWithProblem(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	friends, err := h.svc.FindFriends(c.Request.Context(), userID)
+	friends, err := h.svc.FindMutualFriends(c.Request.Context(), userID)
 	if err != nil {
-		c.AbortWithStatus(http.StatusInternalServerError)
+		h.abortWithError(c, err)
 		return
 	}
 
 	c.JSON(200, friends)
 }
 
+func (h *HTTPHandler) CommonFriends(c *gin.Context) {
+	userIDstr := c.Param("userID")
+	userID, err := strconv.Atoi(userIDstr)
+	if err != nil {
+		abortWithProblem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	otherUserIDstr := c.Param("otherUserID")
+	otherUserID, err := strconv.Atoi(otherUserIDstr)
+	if err != nil {
+		abortWithProblem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	friends, err := h.svc.FindCommonFriends(c.Request.Context(), userID, otherUserID)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	c.JSON(200, friends)
+}
+
+func (h *HTTPHandler) Followers(c *gin.Context) {
+	userIDstr := c.Param("userID")
+	userID, err := strconv.Atoi(userIDstr)
+	if err != nil {
+		abortWithProblem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	followers, err := h.svc.FindFollowers(c.Request.Context(), userID)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	c.JSON(200, followers)
+}
+
+func (h *HTTPHandler) Suggestions(c *gin.Context) {
+	userIDstr := c.Param("userID")
+	userID, err := strconv.Atoi(userIDstr)
+	if err != nil {
+		abortWithProblem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	limit, err := strconv.ParseUint(c.DefaultQuery("limit", strconv.Itoa(defaultLimit)), 10, 32)
+	if err != nil {
+		abortWithProblem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if limit == 0 || limit > maxLimit {
+		abortWithProblem(c, http.StatusBadRequest, fmt.Sprintf("limit must be in range 1..%d", maxLimit))
+		return
+	}
+
+	offset, err := strconv.ParseUint(c.DefaultQuery("offset", "0"), 10, 32)
+	if err != nil {
+		abortWithProblem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	suggestions, err := h.svc.FindSuggestions(c.Request.Context(), userID, uint(limit), uint(offset))
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	c.JSON(200, suggestions)
+}
+
 func (h *HTTPHandler) Name(c *gin.Context) {
 	userIDstr := c.Param("userID")
 	userID, err := strconv.Atoi(userIDstr)
 	if err != nil {
-		c.AbortWithStatus(http.StatusBadRequest)
+		abortWithProblem(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	name, err := h.svc.GetName(c.Request.Context(), userID)
 	if err != nil {
-		c.AbortWithStatus(http.StatusInternalServerError)
+		h.abortWithError(c, err)
 		return
 	}
 
 	c.JSON(200, name)
 }
+
+// contactMaxBytes верхняя оценка размера одного контакта в JSON: каждый символ имени
+// может прийти суррогатной парой \uXXXX\uXXXX, плюс номер или хеш, ключи и разделители
+const contactMaxBytes = entities.ContactNameMaxLength*12 + entities.HashedContactLength + 128
+
+// requestMaxOverheadBytes запас на поля запроса помимо списка контактов
+const requestMaxOverheadBytes = 4096
+
+// limitedBody тело запроса за http.MaxBytesReader, считает прочитанное,
+// чтобы отличить превышение лимита от некорректного JSON
+type limitedBody struct {
+	io.ReadCloser
+	limit int64
+	read  int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	return n, err
+}
+
+// bindContactsJSON разбирает тело запроса с пачкой контактов, размер тела ограничен
+// из лимита контактов и длины полей, при превышении отвечает 413
+func (h *HTTPHandler) bindContactsJSON(c *gin.Context, obj interface{}) bool {
+	var body *limitedBody
+	if h.maxContacts > 0 {
+		limit := int64(h.maxContacts)*contactMaxBytes + requestMaxOverheadBytes
+		if c.Request.ContentLength > limit {
+			abortWithProblem(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body is larger than %d bytes", limit))
+			return false
+		}
+		body = &limitedBody{ReadCloser: http.MaxBytesReader(c.Writer, c.Request.Body, limit), limit: limit}
+		c.Request.Body = body
+	}
+
+	if err := c.ShouldBindJSON(obj); err != nil {
+		if body != nil && body.read >= body.limit {
+			abortWithProblem(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body is larger than %d bytes", body.limit))
+			return false
+		}
+		abortWithProblem(c, http.StatusBadRequest, err.Error())
+		return false
+	}
+
+	return true
+}
+
+// checkContactsBatch отклоняет запрос, если в нем больше контактов, чем разрешено
+func (h *HTTPHandler) checkContactsBatch(c *gin.Context, size int) bool {
+	if h.maxContacts > 0 && size > h.maxContacts {
+		abortWithProblem(c, http.StatusUnprocessableEntity, fmt.Sprintf("too many contacts: %d, max %d", size, h.maxContacts))
+		return false
+	}
+
+	return true
+}
+
+// parseFriendsQuery разбирает параметры списка друзей:
+// cursor, limit, sort=name|added_at (с "-" для обратного порядка),
+// phone_number - точное совпадение номера, сортировка и операторы по номеру не поддерживаются,
+// потому что номера хранятся зашифрованными,
+// остальные параметры - фильтры по storage.FriendsFilterColumns
+func parseFriendsQuery(c *gin.Context) (storage.FriendsQuery, error) {
+	var query storage.FriendsQuery
+
+	limit, err := strconv.ParseUint(c.DefaultQuery("limit", strconv.Itoa(defaultLimit)), 10, 32)
+	if err != nil {
+		return query, err
+	}
+	if limit == 0 || limit > maxLimit {
+		return query, fmt.Errorf("limit must be in range 1..%d", maxLimit)
+	}
+	query.Limit = uint(limit)
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		query.Cursor, err = strconv.Atoi(cursor)
+		if err != nil {
+			return query, err
+		}
+	}
+
+	sort := c.Query("sort")
+	query.Desc = strings.HasPrefix(sort, "-")
+	query.Sort = strings.TrimPrefix(sort, "-")
+	switch query.Sort {
+	case storage.FriendsSortDefault, storage.FriendsSortName, storage.FriendsSortAddedAt:
+	case "phone":
+		// сортировка по номеру была до шифрования номеров, клиенту объясняется, на что заменить
+		return query, fmt.Errorf("sort %q is no longer supported: phone numbers are stored encrypted, use name or added_at", sort)
+	default:
+		return query, fmt.Errorf("unknown sort %q", sort)
+	}
+
+	query.PhoneNumber = c.Query("phone_number")
+
+	filter, err := storage.FriendsFilterColumns.ParseQuery(c.Request.URL.Query(), "cursor", "limit", "sort", "phone_number")
+	if err != nil {
+		return query, err
+	}
+	query.Filter = filter
+
+	return query, nil
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"integ/entities"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// serviceMock методы, не нужные тесту, не реализованы
+type serviceMock struct {
+	RelationService
+
+	saved entities.ContactList
+}
+
+func (s *serviceMock) SaveContacts(ctx context.Context, userID int, contacts entities.ContactList) (*entities.SaveContactsResult, error) {
+	s.saved = contacts
+	return &entities.SaveContactsResult{}, nil
+}
+
+func TestHTTPHandler_AddContactsBodyLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	svc := &serviceMock{}
+	handler := NewHTTPHandler(svc, logrus.NewEntry(logrus.New()), WithMaxContacts(1))
+	router := gin.New()
+	router.POST("/users/:userID/contacts", handler.AddContacts)
+
+	limit := contactMaxBytes + requestMaxOverheadBytes
+	tooLarge := `{"contacts":[{"name":"` + strings.Repeat("a", limit) + `","phoneNumber":"+79831234567"}]}`
+
+	tests := []struct {
+		name   string
+		body   io.Reader
+		status int
+	}{
+		{
+			name:   "в пределах лимита",
+			body:   strings.NewReader(`{"contacts":[{"name":"Мама","phoneNumber":"+79831234567"}]}`),
+			status: http.StatusOK,
+		},
+		{
+			name:   "заявленная длина больше лимита",
+			body:   strings.NewReader(tooLarge),
+			status: http.StatusRequestEntityTooLarge,
+		},
+		{
+			// без Content-Length, превышение обнаруживается при чтении
+			name:   "chunked тело больше лимита",
+			body:   io.MultiReader(strings.NewReader(tooLarge)),
+			status: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:   "некорректный JSON",
+			body:   strings.NewReader(`{"contacts":`),
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/users/1/contacts", tt.body)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("ожидался статус %d, получен %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+		})
+	}
+
+	if len(svc.saved) != 1 {
+		t.Fatalf("в сервис должен был попасть один контакт, получено %d", len(svc.saved))
+	}
+}
+
+func (s *serviceMock) FindFollowers(ctx context.Context, userID int) (entities.FollowerList, error) {
+	return entities.FollowerList{{UserID: 2, Name: "Мама"}}, nil
+}
+
+func TestHTTPHandler_FollowersWithoutPhone(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	handler := NewHTTPHandler(&serviceMock{}, logrus.NewEntry(logrus.New()))
+	router := gin.New()
+	router.GET("/users/:userID/followers", handler.Followers)
+
+	req := httptest.NewRequest(http.MethodGet, "/users/1/followers", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("ожидался статус 200, получен %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var followers []map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &followers); err != nil {
+		t.Fatal(err)
+	}
+	if len(followers) != 1 || followers[0]["name"] != "Мама" {
+		t.Fatalf("ожидался один подписчик, получено %s", rec.Body.String())
+	}
+	if _, ok := followers[0]["phone_number"]; ok {
+		t.Fatalf("номер подписчика не должен отдаваться, получено %s", rec.Body.String())
+	}
+}
+
+func TestHTTPHandler_FriendsSortPhoneRemoved(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	handler := NewHTTPHandler(&serviceMock{}, logrus.NewEntry(logrus.New()))
+	router := gin.New()
+	router.GET("/users/:userID/friends", handler.Friends)
+
+	for _, sort := range []string{"phone", "-phone"} {
+		req := httptest.NewRequest(http.MethodGet, "/users/1/friends?sort="+sort, nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "no longer supported") {
+			t.Fatalf("сортировка %s: ожидался статус 400 с описанием замены, получен %d: %s", sort, rec.Code, rec.Body.String())
+		}
+	}
+}
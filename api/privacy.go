@@ -0,0 +1,123 @@
+package api
+
+import (
+	"integ/entities"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type UpdatePrivacyRequest struct {
+	Discoverability entities.Discoverability `json:"discoverability" binding:"required,oneof=everyone contacts nobody"`
+}
+
+type BlockUserRequest struct {
+	UserID int `json:"user_id" binding:"required"`
+}
+
+func (h *HTTPHandler) GetPrivacy(c *gin.Context) {
+	userIDstr := c.Param("userID")
+	userID, err := strconv.Atoi(userIDstr)
+	if err != nil {
+		abortWithProblem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	settings, err := h.svc.GetPrivacy(c.Request.Context(), userID)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+func (h *HTTPHandler) UpdatePrivacy(c *gin.Context) {
+	userIDstr := c.Param("userID")
+	userID, err := strconv.Atoi(userIDstr)
+	if err != nil {
+		abortWithProblem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var privacyRequest UpdatePrivacyRequest
+
+	if err := c.ShouldBindJSON(&privacyRequest); err != nil {
+		abortWithProblem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	settings, err := h.svc.UpdatePrivacy(c.Request.Context(), userID, entities.PrivacySettings{
+		Discoverability: privacyRequest.Discoverability,
+	})
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+func (h *HTTPHandler) Blocks(c *gin.Context) {
+	userIDstr := c.Param("userID")
+	userID, err := strconv.Atoi(userIDstr)
+	if err != nil {
+		abortWithProblem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	blocked, err := h.svc.FindBlockedUsers(c.Request.Context(), userID)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, blocked)
+}
+
+func (h *HTTPHandler) BlockUser(c *gin.Context) {
+	userIDstr := c.Param("userID")
+	userID, err := strconv.Atoi(userIDstr)
+	if err != nil {
+		abortWithProblem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var blockRequest BlockUserRequest
+
+	if err := c.ShouldBindJSON(&blockRequest); err != nil {
+		abortWithProblem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.svc.BlockUser(c.Request.Context(), userID, blockRequest.UserID); err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *HTTPHandler) UnblockUser(c *gin.Context) {
+	userIDstr := c.Param("userID")
+	userID, err := strconv.Atoi(userIDstr)
+	if err != nil {
+		abortWithProblem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	blockedUserIDstr := c.Param("blockedUserID")
+	blockedUserID, err := strconv.Atoi(blockedUserIDstr)
+	if err != nil {
+		abortWithProblem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.svc.UnblockUser(c.Request.Context(), userID, blockedUserID); err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
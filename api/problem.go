@@ -0,0 +1,77 @@
+package api
+
+import (
+	"errors"
+	"integ/phone"
+	"integ/service"
+	"integ/storage"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const problemContentType = "application/problem+json"
+
+// Problem тело ответа с ошибкой в формате RFC 7807
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+}
+
+// errorKind вид ошибки сервиса и ответ на нее, клиенту отдается только фиксированное описание
+type errorKind struct {
+	target error
+	status int
+	detail string
+}
+
+// errorKinds проверяются по порядку, текст исходной ошибки (сообщения postgres,
+// обертки слоев) в ответ не попадает
+var errorKinds = []errorKind{
+	{target: storage.ErrInvalidCursor, status: http.StatusBadRequest, detail: "cursor does not point to a row of the result"},
+	{target: storage.ErrNotFound, status: http.StatusNotFound, detail: "resource not found"},
+	{target: service.ErrSyncTokenMismatch, status: http.StatusConflict, detail: "sync token is outdated, full sync is required"},
+	{target: storage.ErrConflict, status: http.StatusConflict, detail: "resource conflicts with existing data"},
+	{target: phone.ErrInvalidNumber, status: http.StatusUnprocessableEntity, detail: "invalid phone number"},
+	{target: service.ErrVerificationCode, status: http.StatusUnprocessableEntity, detail: "invalid verification code"},
+	{target: service.ErrVerificationExpired, status: http.StatusUnprocessableEntity, detail: "verification code expired"},
+	{target: storage.ErrInvalid, status: http.StatusUnprocessableEntity, detail: "request data is invalid"},
+	{target: service.ErrVerificationTooSoon, status: http.StatusTooManyRequests, detail: "verification code was requested too recently"},
+	{target: service.ErrVerificationAttempts, status: http.StatusTooManyRequests, detail: "too many verification attempts"},
+}
+
+// errorProblem сопоставляет ошибку сервиса с HTTP статусом и описанием для клиента
+func errorProblem(err error) (int, string) {
+	for _, kind := range errorKinds {
+		if errors.Is(err, kind.target) {
+			return kind.status, kind.detail
+		}
+	}
+
+	return http.StatusInternalServerError, ""
+}
+
+// abortWithError прерывает запрос с ответом problem details,
+// подробности ошибок клиенту не отдаются, внутренние пишутся в лог
+func (h *HTTPHandler) abortWithError(c *gin.Context, err error) {
+	status, detail := errorProblem(err)
+	if status == http.StatusInternalServerError {
+		h.log.WithError(err).Error("Request failed")
+	}
+
+	abortWithProblem(c, status, detail)
+}
+
+func abortWithProblem(c *gin.Context, status int, detail string) {
+	c.Header("Content-Type", problemContentType)
+	c.AbortWithStatusJSON(status, Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: c.Request.URL.Path,
+	})
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"integ/phone"
+	"integ/storage"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+func TestHTTPHandler_AbortWithError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	handler := NewHTTPHandler(&serviceMock{}, logrus.NewEntry(logrus.New()))
+
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		detail string
+	}{
+		{
+			name:   "сообщение postgres",
+			err:    fmt.Errorf("failed to save user: %w: %s", storage.ErrConflict, `duplicate key value violates unique constraint "users_phone_index_key"`),
+			status: http.StatusConflict,
+			detail: "resource conflicts with existing data",
+		},
+		{
+			name:   "ошибка проверки с номером",
+			err:    fmt.Errorf("find friends: %w: %q", phone.ErrInvalidNumber, "+7983abc"),
+			status: http.StatusUnprocessableEntity,
+			detail: "invalid phone number",
+		},
+		{
+			name:   "внутренняя ошибка",
+			err:    errors.New("failed to execute a query: connection reset"),
+			status: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(rec)
+			c.Request = httptest.NewRequest(http.MethodGet, "/users/1", nil)
+
+			handler.abortWithError(c, tt.err)
+
+			var problem Problem
+			if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+				t.Fatal(err)
+			}
+			if rec.Code != tt.status || problem.Detail != tt.detail {
+				t.Fatalf("ожидался статус %d и описание %q, получено %d %q", tt.status, tt.detail, rec.Code, problem.Detail)
+			}
+			if strings.Contains(rec.Body.String(), "failed") {
+				t.Fatalf("текст внутренней ошибки не должен попадать в ответ: %s", rec.Body.String())
+			}
+		})
+	}
+}
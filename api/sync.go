@@ -0,0 +1,71 @@
+package api
+
+import (
+	"integ/entities"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SyncContactsDeltaRequest struct {
+	SyncToken string               `json:"sync_token" binding:"required"`
+	Added     entities.ContactList `json:"added"`
+	Removed   entities.ContactList `json:"removed"`
+}
+
+func (h *HTTPHandler) SyncContacts(c *gin.Context) {
+	userIDstr := c.Param("userID")
+	userID, err := strconv.Atoi(userIDstr)
+	if err != nil {
+		abortWithProblem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var contactRequest AddContactRequest
+
+	if !h.bindContactsJSON(c, &contactRequest) {
+		return
+	}
+	if !h.checkContactsBatch(c, len(contactRequest.Contacts)) {
+		return
+	}
+
+	result, err := h.svc.SyncContacts(c.Request.Context(), userID, contactRequest.Contacts)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+func (h *HTTPHandler) SyncContactsDelta(c *gin.Context) {
+	userIDstr := c.Param("userID")
+	userID, err := strconv.Atoi(userIDstr)
+	if err != nil {
+		abortWithProblem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var deltaRequest SyncContactsDeltaRequest
+
+	if !h.bindContactsJSON(c, &deltaRequest) {
+		return
+	}
+	if !h.checkContactsBatch(c, len(deltaRequest.Added)+len(deltaRequest.Removed)) {
+		return
+	}
+
+	result, err := h.svc.SyncContactsDelta(c.Request.Context(), userID, entities.ContactsDelta{
+		SyncToken: deltaRequest.SyncToken,
+		Added:     deltaRequest.Added,
+		Removed:   deltaRequest.Removed,
+	})
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
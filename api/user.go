@@ -0,0 +1,114 @@
+package api
+
+import (
+	"integ/entities"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreateUserRequest struct {
+	Name        string `json:"name" binding:"required,max=128"`
+	PhoneNumber string `json:"phone_number" binding:"required"`
+}
+
+type UpdateUserRequest struct {
+	Name        *string `json:"name" binding:"omitempty,min=1,max=128"`
+	PhoneNumber *string `json:"phone_number" binding:"omitempty,min=1"`
+}
+
+func (h *HTTPHandler) CreateUser(c *gin.Context) {
+	var userRequest CreateUserRequest
+
+	if err := c.ShouldBindJSON(&userRequest); err != nil {
+		abortWithProblem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	user, err := h.svc.CreateUser(c.Request.Context(), &entities.User{
+		Name:        userRequest.Name,
+		PhoneNumber: userRequest.PhoneNumber,
+	})
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, user)
+}
+
+func (h *HTTPHandler) GetUser(c *gin.Context) {
+	userIDstr := c.Param("userID")
+	userID, err := strconv.Atoi(userIDstr)
+	if err != nil {
+		abortWithProblem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	user, err := h.svc.GetUser(c.Request.Context(), userID)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+func (h *HTTPHandler) UpdateUser(c *gin.Context) {
+	userIDstr := c.Param("userID")
+	userID, err := strconv.Atoi(userIDstr)
+	if err != nil {
+		abortWithProblem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var userRequest UpdateUserRequest
+
+	if err := c.ShouldBindJSON(&userRequest); err != nil {
+		abortWithProblem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	user, err := h.svc.UpdateUser(c.Request.Context(), userID, entities.UserUpdate{
+		Name:        userRequest.Name,
+		PhoneNumber: userRequest.PhoneNumber,
+	})
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+func (h *HTTPHandler) DeleteUser(c *gin.Context) {
+	userIDstr := c.Param("userID")
+	userID, err := strconv.Atoi(userIDstr)
+	if err != nil {
+		abortWithProblem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// erase=true удаляет и связи с пользователем, оставляя обезличенную запись
+	erase := false
+	if value := c.Query("erase"); value != "" {
+		erase, err = strconv.ParseBool(value)
+		if err != nil {
+			abortWithProblem(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	if erase {
+		err = h.svc.EraseUser(c.Request.Context(), userID)
+	} else {
+		err = h.svc.DeleteUser(c.Request.Context(), userID)
+	}
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
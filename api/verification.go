@@ -0,0 +1,53 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ConfirmPhoneRequest struct {
+	Code string `json:"code" binding:"required,numeric,max=16"`
+}
+
+func (h *HTTPHandler) RequestPhoneVerification(c *gin.Context) {
+	userIDstr := c.Param("userID")
+	userID, err := strconv.Atoi(userIDstr)
+	if err != nil {
+		abortWithProblem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	started, err := h.svc.RequestPhoneVerification(c.Request.Context(), userID)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, started)
+}
+
+func (h *HTTPHandler) ConfirmPhoneVerification(c *gin.Context) {
+	userIDstr := c.Param("userID")
+	userID, err := strconv.Atoi(userIDstr)
+	if err != nil {
+		abortWithProblem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var confirmRequest ConfirmPhoneRequest
+
+	if err := c.ShouldBindJSON(&confirmRequest); err != nil {
+		abortWithProblem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	user, err := h.svc.ConfirmPhoneVerification(c.Request.Context(), userID, confirmRequest.Code)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// RoleAdmin роль, которой разрешен доступ к данным любого пользователя
+const RoleAdmin = "admin"
+
+// leeway допустимое расхождение часов при проверке exp и nbf
+const leeway = 30 * time.Second
+
+var (
+	ErrNoKeys       = errors.New("no signing keys configured")
+	ErrInvalidToken = errors.New("invalid token")
+)
+
+// Claims утверждения токена, Subject - идентификатор пользователя
+type Claims struct {
+	Role string `json:"role,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// UserID идентификатор пользователя из subject
+func (c *Claims) UserID() (int, error) {
+	return strconv.Atoi(c.Subject)
+}
+
+func (c *Claims) IsAdmin() bool {
+	return c.Role == RoleAdmin
+}
+
+// Authenticator проверяет токены, подписанные HMAC ключами из конфигурации
+type Authenticator struct {
+	keys   map[string][]byte
+	parser *jwt.Parser
+}
+
+// NewAuthenticator keys - секреты по идентификатору ключа (kid),
+// токен без kid принимается только если ключ один
+func NewAuthenticator(issuer string, keys map[string]string) (*Authenticator, error) {
+	if len(keys) == 0 {
+		return nil, ErrNoKeys
+	}
+
+	secrets := make(map[string][]byte, len(keys))
+	for kid, secret := range keys {
+		if secret == "" {
+			return nil, fmt.Errorf("signing key %q is empty", kid)
+		}
+		secrets[kid] = []byte(secret)
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"HS256", "HS384", "HS512"}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(leeway),
+	}
+	if issuer != "" {
+		opts = append(opts, jwt.WithIssuer(issuer))
+	}
+
+	return &Authenticator{
+		keys:   secrets,
+		parser: jwt.NewParser(opts...),
+	}, nil
+}
+
+// Parse проверяет подпись, срок действия и издателя токена
+func (a *Authenticator) Parse(token string) (*Claims, error) {
+	claims := &Claims{}
+	if _, err := a.parser.ParseWithClaims(token, claims, a.key); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: subject is empty", ErrInvalidToken)
+	}
+
+	return claims, nil
+}
+
+func (a *Authenticator) key(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		if len(a.keys) != 1 {
+			return nil, errors.New("token has no key id")
+		}
+		for _, key := range a.keys {
+			return key, nil
+		}
+	}
+
+	key, ok := a.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	return key, nil
+}
@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func sign(t *testing.T, kid, secret string, claims Claims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString([]byte(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func validClaims(subject string) Claims {
+	return Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   subject,
+			Issuer:    "integ",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+}
+
+func TestAuthenticator_Parse(t *testing.T) {
+	a, err := NewAuthenticator("integ", map[string]string{"k1": "secret1", "k2": "secret2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := a.Parse(sign(t, "k2", "secret2", validClaims("42")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	userID, err := claims.UserID()
+	if err != nil || userID != 42 {
+		t.Fatalf("ожидался пользователь 42, получен %d (%v)", userID, err)
+	}
+	if claims.IsAdmin() {
+		t.Fatal("токен без роли не должен давать права администратора")
+	}
+}
+
+func TestAuthenticator_ParseInvalid(t *testing.T) {
+	a, err := NewAuthenticator("integ", map[string]string{"k1": "secret1", "k2": "secret2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expired := validClaims("42")
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+
+	noExp := validClaims("42")
+	noExp.ExpiresAt = nil
+
+	otherIssuer := validClaims("42")
+	otherIssuer.Issuer = "other"
+
+	cases := map[string]string{
+		"чужой ключ":      sign(t, "k1", "secret2", validClaims("42")),
+		"неизвестный kid": sign(t, "k3", "secret1", validClaims("42")),
+		"без kid":         sign(t, "", "secret1", validClaims("42")),
+		"истекший":        sign(t, "k1", "secret1", expired),
+		"без exp":         sign(t, "k1", "secret1", noExp),
+		"другой издатель": sign(t, "k1", "secret1", otherIssuer),
+		"без subject":     sign(t, "k1", "secret1", validClaims("")),
+		"не токен":        "not a token",
+	}
+
+	for name, token := range cases {
+		if _, err := a.Parse(token); !errors.Is(err, ErrInvalidToken) {
+			t.Fatalf("%s: ожидалась ErrInvalidToken, получено %v", name, err)
+		}
+	}
+}
+
+func TestAuthenticator_SingleKeyWithoutKid(t *testing.T) {
+	a, err := NewAuthenticator("", map[string]string{"k1": "secret1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := a.Parse(sign(t, "", "secret1", validClaims("42"))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewAuthenticator_NoKeys(t *testing.T) {
+	if _, err := NewAuthenticator("integ", nil); !errors.Is(err, ErrNoKeys) {
+		t.Fatalf("ожидалась ErrNoKeys, получено %v", err)
+	}
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 
@@ -11,8 +12,65 @@ import (
 )
 
 type Config struct {
-	ListenAddr string
-	Database   Database
+	ListenAddr   string
+	Database     Database
+	Phone        Phone
+	Contacts     Contacts
+	Auth         Auth
+	Verification Verification
+	Encryption   Encryption
+	Audit        Audit
+	// Dev режим локального запуска, допускает отладочные настройки вроде SMS в лог
+	Dev bool
+}
+
+type Phone struct {
+	DefaultRegion string
+	// HashKey ключ HMAC для хешей номеров при поиске контактов по хешам,
+	// должен совпадать с ключом в клиентах
+	HashKey string
+}
+
+type Contacts struct {
+	MaxBatchSize int
+}
+
+type Verification struct {
+	CodeLength     int
+	CodeTTL        time.Duration
+	MaxAttempts    int
+	ResendInterval time.Duration
+	// Sender способ доставки SMS с кодами: file - запись в SMSFile,
+	// log - только запись в лог без кода, допускается лишь в режиме Dev.
+	// Без значения сервис запускается только в режиме Dev, с отправкой в лог
+	Sender string
+	// SMSFile файл, в который пишутся SMS с кодами для Sender file
+	SMSFile string
+}
+
+type Auth struct {
+	// Issuer ожидаемый iss токенов, пустой - не проверяется
+	Issuer string
+	// Keys HMAC ключи подписи токенов по идентификатору ключа (kid)
+	Keys map[string]string
+}
+
+type Audit struct {
+	// Retention сколько хранятся события журнала аудита
+	Retention time.Duration
+	// PruneInterval как часто удаляются устаревшие события
+	PruneInterval time.Duration
+}
+
+type Encryption struct {
+	// PrimaryKey идентификатор ключа, которым шифруются новые номера
+	PrimaryKey string
+	// Keys ключи AES-256 в base64 по идентификатору, старые ключи оставляются
+	// до перешифрования номеров командой --encrypt-phones
+	Keys map[string]string
+	// IndexKey ключ HMAC слепого индекса номеров и хешей номеров от клиентов,
+	// после шифрования номеров не меняется
+	IndexKey string
 }
 
 type Database struct {
@@ -22,6 +80,12 @@ type Database struct {
 	Password string
 	Name     string
 	Schema   string
+
+	MaxConns          int32
+	MinConns          int32
+	MaxConnLifetime   time.Duration
+	MaxConnIdleTime   time.Duration
+	HealthCheckPeriod time.Duration
 }
 
 func LoadConfig(configFileName string) (*Config, error) {
@@ -60,6 +124,8 @@ func LoadConfig(configFileName string) (*Config, error) {
 func loadDefaultSettingsFor(v *viper.Viper) {
 	// Порт, который слушает сервис
 	v.SetDefault("ListenAddr", ":9090")
+	// режим локального запуска
+	v.SetDefault("dev", false)
 
 	// настройки подключения к бд
 	v.SetDefault("database.host", "127.0.0.1")
@@ -68,6 +134,41 @@ func loadDefaultSettingsFor(v *viper.Viper) {
 	v.SetDefault("database.password", "phone")
 	v.SetDefault("database.name", "phonedb")
 	v.SetDefault("database.schema", "")
+
+	// настройки пула соединений к бд
+	v.SetDefault("database.maxConns", 10)
+	v.SetDefault("database.minConns", 2)
+	v.SetDefault("database.maxConnLifetime", "1h")
+	v.SetDefault("database.maxConnIdleTime", "30m")
+	v.SetDefault("database.healthCheckPeriod", "1m")
+
+	// регион для номеров телефонов без кода страны
+	v.SetDefault("phone.defaultRegion", "RU")
+	// ключ хешей номеров, без значения по умолчанию сервис не запустится
+	v.SetDefault("phone.hashKey", "")
+
+	// максимальное количество контактов в одном запросе загрузки или синхронизации
+	v.SetDefault("contacts.maxBatchSize", 5000)
+
+	// одноразовые коды подтверждения номера телефона
+	v.SetDefault("verification.codeLength", 6)
+	v.SetDefault("verification.codeTTL", "10m")
+	v.SetDefault("verification.maxAttempts", 5)
+	v.SetDefault("verification.resendInterval", "1m")
+	// способ доставки SMS, без значения по умолчанию сервис вне режима dev не запустится
+	v.SetDefault("verification.sender", "")
+	v.SetDefault("verification.smsFile", "")
+
+	// издатель токенов доступа, ключи подписи задаются только в конфигурации
+	v.SetDefault("auth.issuer", "integ")
+
+	// журнал аудита загрузки и чтения контактов
+	v.SetDefault("audit.retention", "2160h")
+	v.SetDefault("audit.pruneInterval", "1h")
+
+	// шифрование номеров, ключи задаются только в конфигурации
+	v.SetDefault("encryption.primaryKey", "")
+	v.SetDefault("encryption.indexKey", "")
 }
 
 func (d *Database) ToDataSourceName() string {
@@ -0,0 +1,37 @@
+package entities
+
+import "time"
+
+// AuditAction операция с контактами, попадающая в журнал аудита
+type AuditAction string
+
+const (
+	AuditSaveContacts       AuditAction = "save_contacts"
+	AuditSaveHashedContacts AuditAction = "save_hashed_contacts"
+	AuditSyncContacts       AuditAction = "sync_contacts"
+	AuditSyncContactsDelta  AuditAction = "sync_contacts_delta"
+	AuditFindFriends        AuditAction = "find_friends"
+	AuditGetName            AuditAction = "get_name"
+)
+
+// AuditEvent запись журнала аудита: Actor выполнил Action над данными Target,
+// Count - число загруженных или прочитанных контактов.
+// ActorSubject и ActorRole - subject и роль токена инициатора, ActorUserID заполнен,
+// если subject - идентификатор пользователя
+type AuditEvent struct {
+	EventID      int64       `json:"event_id"`
+	Action       AuditAction `json:"action"`
+	ActorUserID  *int        `json:"actor_user_id"`
+	ActorSubject string      `json:"actor_subject,omitempty"`
+	ActorRole    string      `json:"actor_role,omitempty"`
+	TargetUserID int         `json:"target_user_id"`
+	Count        int         `json:"count"`
+	RequestID    string      `json:"request_id,omitempty"`
+	CreatedAt    time.Time   `json:"created_at"`
+}
+
+// AuditPage страница журнала аудита, NextCursor пустой на последней странице
+type AuditPage struct {
+	Events     []*AuditEvent `json:"events"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
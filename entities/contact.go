@@ -1,8 +1,98 @@
 package entities
 
+import (
+	"errors"
+	"unicode/utf8"
+)
+
 type ContactList []Contact
 
 type Contact struct {
 	Name        string `json:"name"`
 	PhoneNumber string `json:"phoneNumber"`
 }
+
+const (
+	// ContactNameMaxLength максимальная длина имени контакта в символах
+	ContactNameMaxLength = 256
+	// ContactPhoneMaxLength максимальная длина номера до нормализации,
+	// с учетом пробелов, скобок и дефисов
+	ContactPhoneMaxLength = 32
+)
+
+var (
+	ErrContactPhoneEmpty   = errors.New("phone number is empty")
+	ErrContactPhoneTooLong = errors.New("phone number is too long")
+	ErrContactPhoneFormat  = errors.New("phone number contains invalid characters")
+	ErrContactNameTooLong  = errors.New("name is too long")
+	ErrContactNameEncoding = errors.New("name is not valid UTF-8")
+)
+
+// Validate проверяет длину полей и допустимые символы номера,
+// принадлежность номера региону проверяется при нормализации
+func (c Contact) Validate() error {
+	if c.PhoneNumber == "" {
+		return ErrContactPhoneEmpty
+	}
+	if len(c.PhoneNumber) > ContactPhoneMaxLength {
+		return ErrContactPhoneTooLong
+	}
+	for i, r := range c.PhoneNumber {
+		switch {
+		case r >= '0' && r <= '9':
+		case r == ' ', r == '-', r == '(', r == ')', r == '.':
+		case r == '+' && i == 0:
+		default:
+			return ErrContactPhoneFormat
+		}
+	}
+
+	return validateContactName(c.Name)
+}
+
+func validateContactName(name string) error {
+	if !utf8.ValidString(name) {
+		return ErrContactNameEncoding
+	}
+	if utf8.RuneCountInString(name) > ContactNameMaxLength {
+		return ErrContactNameTooLong
+	}
+
+	return nil
+}
+
+type HashedContactList []HashedContact
+
+// HashedContact контакт, номер которого клиент передает в виде
+// HMAC-SHA256 нормализованного номера в hex
+type HashedContact struct {
+	Name      string `json:"name"`
+	PhoneHash string `json:"phoneHash"`
+}
+
+// HashedContactLength длина хеша номера в hex
+const HashedContactLength = 64
+
+var ErrContactHashFormat = errors.New("phone hash must be 64 hex characters")
+
+func (c HashedContact) Validate() error {
+	if len(c.PhoneHash) != HashedContactLength {
+		return ErrContactHashFormat
+	}
+	for _, r := range c.PhoneHash {
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'f' || r >= 'A' && r <= 'F') {
+			return ErrContactHashFormat
+		}
+	}
+
+	return validateContactName(c.Name)
+}
+
+// ContactRejection контакт, не принятый при загрузке,
+// Index - позиция контакта в загруженном списке, List - список изменений,
+// если их несколько (removed для удаляемых при синхронизации изменений)
+type ContactRejection struct {
+	Index  int    `json:"index"`
+	List   string `json:"list,omitempty"`
+	Reason string `json:"reason"`
+}
@@ -0,0 +1,30 @@
+package entities
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestContact_Validate(t *testing.T) {
+	cases := []struct {
+		contact Contact
+		want    error
+	}{
+		{Contact{Name: "Мама", PhoneNumber: "+7 (983) 123-45-67"}, nil},
+		{Contact{Name: "", PhoneNumber: "89831234567"}, nil},
+		{Contact{Name: strings.Repeat("я", ContactNameMaxLength), PhoneNumber: "89831234567"}, nil},
+		{Contact{Name: "Мама", PhoneNumber: ""}, ErrContactPhoneEmpty},
+		{Contact{Name: "Мама", PhoneNumber: strings.Repeat("1", ContactPhoneMaxLength+1)}, ErrContactPhoneTooLong},
+		{Contact{Name: "Мама", PhoneNumber: "8983abc4567"}, ErrContactPhoneFormat},
+		{Contact{Name: "Мама", PhoneNumber: "7+9831234567"}, ErrContactPhoneFormat},
+		{Contact{Name: strings.Repeat("я", ContactNameMaxLength+1), PhoneNumber: "89831234567"}, ErrContactNameTooLong},
+		{Contact{Name: "\xff", PhoneNumber: "89831234567"}, ErrContactNameEncoding},
+	}
+
+	for _, c := range cases {
+		if err := c.contact.Validate(); !errors.Is(err, c.want) {
+			t.Fatalf("%q: ожидалась ошибка %v, получена %v", c.contact.PhoneNumber, c.want, err)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package entities
+
+type Event interface {
+	EventType() string
+}
+
+// ContactJoinedEvent контакт пользователя UserID зарегистрировался
+type ContactJoinedEvent struct {
+	UserID        int    `json:"user_id"`
+	ContactUserID int    `json:"contact_user_id"`
+	ContactName   string `json:"contact_name"`
+}
+
+func (ContactJoinedEvent) EventType() string {
+	return "contact_joined"
+}
@@ -0,0 +1,47 @@
+package entities
+
+import "time"
+
+// UserExport все данные, которые хранятся о пользователе
+type UserExport struct {
+	ExportedAt        time.Time                  `json:"exported_at"`
+	User              ExportedUser               `json:"user"`
+	Relations         []ExportedRelation         `json:"relations"`
+	IncomingRelations []ExportedIncomingRelation `json:"incoming_relations"`
+	PendingContacts   []ExportedPendingContact   `json:"pending_contacts"`
+	Blocks            []ExportedBlock            `json:"blocks"`
+	AuditEvents       []*AuditEvent              `json:"audit_events"`
+}
+
+type ExportedUser struct {
+	UserID          int             `json:"user_id"`
+	Name            string          `json:"name"`
+	PhoneNumber     string          `json:"phone_number"`
+	PhoneVerifiedAt *time.Time      `json:"phone_verified_at"`
+	Discoverability Discoverability `json:"discoverability"`
+}
+
+// ExportedRelation контакт из адресной книги пользователя
+type ExportedRelation struct {
+	RelationUserID int       `json:"relation_user_id"`
+	ContactName    string    `json:"contact_name"`
+	AddedAt        time.Time `json:"added_at"`
+}
+
+// ExportedIncomingRelation пользователь, у которого экспортируемый пользователь есть в контактах,
+// имя контакта не выгружается: это данные адресной книги другого пользователя
+type ExportedIncomingRelation struct {
+	UserID  int       `json:"user_id"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+type ExportedPendingContact struct {
+	PhoneNumber string    `json:"phone_number"`
+	ContactName string    `json:"contact_name"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type ExportedBlock struct {
+	BlockedUserID int       `json:"blocked_user_id"`
+	CreatedAt     time.Time `json:"created_at"`
+}
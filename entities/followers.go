@@ -0,0 +1,10 @@
+package entities
+
+type FollowerList []Follower
+
+// Follower пользователь, у которого запрашивающий есть в контактах.
+// Номер не отдается: подписчика может не быть в контактах запрашивающего
+type Follower struct {
+	UserID int    `json:"user_id"`
+	Name   string `json:"name"`
+}
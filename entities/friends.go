@@ -1,9 +1,21 @@
 package entities
 
+import "time"
+
 type FriendsList []Friend
 
 type Friend struct {
-	UserID         int    `json:"user_id"`
-	RelationUserID int    `json:"relation_user_id"`
-	PhoneNumber    string `json:"phone_number"`
+	RelationID     int       `json:"relation_id"`
+	UserID         int       `json:"user_id"`
+	RelationUserID int       `json:"relation_user_id"`
+	Name           string    `json:"name"`
+	PhoneNumber    string    `json:"phone_number"`
+	ContactName    string    `json:"contact_name,omitempty"`
+	AddedAt        time.Time `json:"added_at"`
+}
+
+// FriendsPage страница списка друзей, NextCursor пустой на последней странице
+type FriendsPage struct {
+	Friends    FriendsList `json:"friends"`
+	NextCursor string      `json:"next_cursor,omitempty"`
 }
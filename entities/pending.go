@@ -0,0 +1,8 @@
+package entities
+
+// PendingContact номер из загруженных контактов, который еще не зарегистрирован
+type PendingContact struct {
+	UserID      int
+	PhoneNumber string
+	ContactName string
+}
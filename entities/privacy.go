@@ -0,0 +1,25 @@
+package entities
+
+import "time"
+
+// Discoverability кто может найти пользователя по номеру телефона
+type Discoverability string
+
+const (
+	DiscoverableEveryone Discoverability = "everyone"
+	// DiscoverableContacts только те, кто есть в контактах пользователя
+	DiscoverableContacts Discoverability = "contacts"
+	DiscoverableNobody   Discoverability = "nobody"
+)
+
+type PrivacySettings struct {
+	Discoverability Discoverability `json:"discoverability"`
+}
+
+// BlockedUser запись черного списка, данные заблокированного пользователя не раскрываются
+type BlockedUser struct {
+	BlockedUserID int       `json:"blocked_user_id"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type BlockedUserList []BlockedUser
@@ -5,4 +5,14 @@ type RelationList []*Relation
 type Relation struct {
 	UserID         int
 	RelationUserID int
+	// ContactName имя, под которым пользователь записал контакт
+	ContactName string
+}
+
+// SaveContactsResult сколько связей добавлено и сколько уже было известно,
+// Rejected - контакты, не прошедшие проверку
+type SaveContactsResult struct {
+	Created  int                `json:"created"`
+	Existing int                `json:"existing"`
+	Rejected []ContactRejection `json:"rejected,omitempty"`
 }
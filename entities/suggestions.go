@@ -0,0 +1,11 @@
+package entities
+
+type SuggestionList []Suggestion
+
+// Suggestion знакомый знакомых, MutualCount - через скольких контактов пользователя он найден.
+// Номер не отдается: пользователя нет в контактах того, кому он рекомендован
+type Suggestion struct {
+	UserID      int    `json:"user_id"`
+	Name        string `json:"name"`
+	MutualCount int    `json:"mutual_count"`
+}
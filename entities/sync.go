@@ -0,0 +1,14 @@
+package entities
+
+// ContactsDelta изменения адресной книги с момента синхронизации SyncToken
+type ContactsDelta struct {
+	SyncToken string
+	Added     ContactList
+	Removed   ContactList
+}
+
+type SyncContactsResult struct {
+	SaveContactsResult
+	Removed   int    `json:"removed"`
+	SyncToken string `json:"sync_token"`
+}
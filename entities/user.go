@@ -1,7 +1,23 @@
 package entities
 
+type UserList []User
+
 type User struct {
 	UserID      int    `json:"user_id"`
 	Name        string `json:"name"`
 	PhoneNumber string `json:"phone_number"`
+	// PhoneHash HMAC нормализованного номера для поиска по хешам, только для записи
+	PhoneHash string `json:"-"`
+}
+
+// UserUpdate частичное обновление профиля, nil-поля не меняются,
+// PhoneHash задается вместе с PhoneNumber
+type UserUpdate struct {
+	Name        *string
+	PhoneNumber *string
+	PhoneHash   *string
+}
+
+func (u UserUpdate) IsEmpty() bool {
+	return u.Name == nil && u.PhoneNumber == nil
 }
@@ -0,0 +1,20 @@
+package entities
+
+import "time"
+
+// PhoneVerification одноразовый код подтверждения номера,
+// хранится только хеш кода с солью
+type PhoneVerification struct {
+	UserID      int
+	PhoneNumber string
+	CodeHash    []byte
+	Salt        []byte
+	Attempts    int
+	ExpiresAt   time.Time
+	CreatedAt   time.Time
+}
+
+// PhoneVerificationStarted ответ на запрос кода подтверждения
+type PhoneVerificationStarted struct {
+	ExpiresAt time.Time `json:"expires_at"`
+}
@@ -0,0 +1,28 @@
+package events
+
+import (
+	"context"
+	"integ/entities"
+
+	"github.com/sirupsen/logrus"
+)
+
+// LogPublisher пишет события в лог, используется пока нет брокера сообщений
+type LogPublisher struct {
+	log logrus.FieldLogger
+}
+
+func NewLogPublisher(log logrus.FieldLogger) *LogPublisher {
+	return &LogPublisher{
+		log: log,
+	}
+}
+
+func (p *LogPublisher) Publish(ctx context.Context, event entities.Event) error {
+	p.log.WithFields(logrus.Fields{
+		"event":   event.EventType(),
+		"payload": event,
+	}).Info("Event published")
+
+	return nil
+}
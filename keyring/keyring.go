@@ -0,0 +1,175 @@
+package keyring
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// KeySize длина ключа шифрования ключей, AES-256
+const KeySize = 32
+
+// prefix версия формата зашифрованного значения
+const prefix = "v1."
+
+var (
+	ErrNoKeys     = errors.New("no encryption keys configured")
+	ErrUnknownKey = errors.New("unknown encryption key")
+	ErrMalformed  = errors.New("malformed encrypted value")
+)
+
+var encoding = base64.RawURLEncoding
+
+// KeyRing конвертное шифрование AES-GCM: каждое значение шифруется своим случайным ключом данных,
+// который шифруется ключом из связки. Новые значения шифруются основным ключом,
+// расшифровываются любым ключом связки, поэтому ротация - это новый основной ключ
+// и перешифрование старых значений
+type KeyRing struct {
+	primary string
+	keys    map[string]cipher.AEAD
+}
+
+// New keys - ключи в base64 по идентификатору, primary - идентификатор основного ключа
+func New(primary string, keys map[string]string) (*KeyRing, error) {
+	if len(keys) == 0 {
+		return nil, ErrNoKeys
+	}
+	if _, ok := keys[primary]; !ok {
+		return nil, fmt.Errorf("primary key %q: %w", primary, ErrUnknownKey)
+	}
+
+	ring := &KeyRing{
+		primary: primary,
+		keys:    make(map[string]cipher.AEAD, len(keys)),
+	}
+	for kid, encoded := range keys {
+		if kid == "" || strings.Contains(kid, ".") {
+			return nil, fmt.Errorf("invalid key id %q", kid)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("key %q is not valid base64: %w", kid, err)
+		}
+		if len(key) != KeySize {
+			return nil, fmt.Errorf("key %q must be %d bytes, got %d", kid, KeySize, len(key))
+		}
+
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", kid, err)
+		}
+		ring.keys[kid] = aead
+	}
+
+	return ring, nil
+}
+
+// Encrypt шифрует plaintext основным ключом, результат - v1.<kid>.<ключ данных>.<данные>
+func (r *KeyRing) Encrypt(plaintext []byte) (string, error) {
+	dataKey := make([]byte, KeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	data, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	wrappedKey, err := seal(r.keys[r.primary], dataKey)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(data, plaintext)
+	if err != nil {
+		return "", err
+	}
+
+	return prefix + r.primary + "." + encoding.EncodeToString(wrappedKey) + "." + encoding.EncodeToString(ciphertext), nil
+}
+
+// Decrypt расшифровывает значение, полученное Encrypt с любым ключом связки
+func (r *KeyRing) Decrypt(value string) ([]byte, error) {
+	kid, wrappedKey, ciphertext, err := parse(value)
+	if err != nil {
+		return nil, err
+	}
+
+	master, ok := r.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("key %q: %w", kid, ErrUnknownKey)
+	}
+
+	dataKey, err := open(master, wrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	data, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return open(data, ciphertext)
+}
+
+// IsCurrent зашифровано ли value основным ключом
+func (r *KeyRing) IsCurrent(value string) bool {
+	return strings.HasPrefix(value, prefix+r.primary+".")
+}
+
+// IsEncrypted похоже ли value на результат Encrypt, остальные значения считаются открытым текстом
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+func parse(value string) (kid string, wrappedKey, ciphertext []byte, err error) {
+	parts := strings.Split(strings.TrimPrefix(value, prefix), ".")
+	if !IsEncrypted(value) || len(parts) != 3 {
+		return "", nil, nil, ErrMalformed
+	}
+
+	wrappedKey, err = encoding.DecodeString(parts[1])
+	if err != nil {
+		return "", nil, nil, ErrMalformed
+	}
+	ciphertext, err = encoding.DecodeString(parts[2])
+	if err != nil {
+		return "", nil, nil, ErrMalformed
+	}
+
+	return parts[0], wrappedKey, ciphertext, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal результат - nonce и шифротекст
+func seal(aead cipher.AEAD, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(aead cipher.AEAD, sealed []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrMalformed
+	}
+
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,93 @@
+package keyring
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+var (
+	oldKey = base64.StdEncoding.EncodeToString([]byte(strings.Repeat("a", KeySize)))
+	newKey = base64.StdEncoding.EncodeToString([]byte(strings.Repeat("b", KeySize)))
+)
+
+func TestKeyRing_EncryptDecrypt(t *testing.T) {
+	ring, err := New("k1", map[string]string{"k1": oldKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := ring.Encrypt([]byte("+79831234567"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := ring.Encrypt([]byte("+79831234567"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Fatal("шифрование одного значения должно давать разные шифротексты")
+	}
+	if strings.Contains(first, "79831234567") || !IsEncrypted(first) || !ring.IsCurrent(first) {
+		t.Fatalf("неожиданный шифротекст %q", first)
+	}
+
+	plaintext, err := ring.Decrypt(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plaintext) != "+79831234567" {
+		t.Fatalf("ожидался +79831234567, получен %q", plaintext)
+	}
+
+	if _, err := ring.Decrypt(first[:len(first)-2]); !errors.Is(err, ErrMalformed) {
+		t.Fatalf("поврежденное значение должно вернуть ErrMalformed, получено %v", err)
+	}
+}
+
+func TestKeyRing_Rotation(t *testing.T) {
+	old, err := New("k1", map[string]string{"k1": oldKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := old.Encrypt([]byte("+79831234567"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := New("k2", map[string]string{"k1": oldKey, "k2": newKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated.IsCurrent(value) {
+		t.Fatal("значение зашифровано не основным ключом")
+	}
+	plaintext, err := rotated.Decrypt(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plaintext) != "+79831234567" {
+		t.Fatalf("ожидался +79831234567, получен %q", plaintext)
+	}
+
+	withoutOld, err := New("k2", map[string]string{"k2": newKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := withoutOld.Decrypt(value); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("ожидалась ErrUnknownKey, получено %v", err)
+	}
+}
+
+func TestNew_InvalidKeys(t *testing.T) {
+	if _, err := New("k1", nil); !errors.Is(err, ErrNoKeys) {
+		t.Fatalf("ожидалась ErrNoKeys, получено %v", err)
+	}
+	if _, err := New("k2", map[string]string{"k1": oldKey}); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("ожидалась ErrUnknownKey, получено %v", err)
+	}
+	if _, err := New("k1", map[string]string{"k1": base64.StdEncoding.EncodeToString([]byte("short"))}); err == nil {
+		t.Fatal("короткий ключ должен быть отклонен")
+	}
+}
@@ -3,17 +3,24 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"flag"
+	"fmt"
 	"integ/api"
+	"integ/auth"
 	"integ/config"
+	"integ/events"
+	"integ/keyring"
+	"integ/phone"
 	"integ/service"
+	"integ/sms"
 	"integ/storage"
 	"integ/storage/migration"
 	"os"
 	"os/signal"
 
 	"github.com/gin-gonic/gin"
-	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/pflag"
 	"golang.org/x/sync/errgroup"
@@ -30,6 +37,7 @@ func main() {
 		help       = pflag.BoolP("help", "h", false, "show help message")
 		configFile = pflag.StringP("config", "c", "", "name config file only name without extension")
 		debug      = pflag.BoolP("debug", "d", false, "enable debug logging")
+		encrypt    = pflag.Bool("encrypt-phones", false, "encrypt plaintext phone numbers and re-encrypt numbers with the primary key, then exit")
 	)
 
 	pflag.CommandLine.AddGoFlagSet(flag.CommandLine)
@@ -55,38 +63,129 @@ func main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer cancel()
 
-	if err := evolution(conf.Database.ToDataSourceName()); err != nil {
-		log.WithError(err).Fatalln("Failed migrate")
+	normalizer, err := phone.NewNormalizer(conf.Phone.DefaultRegion)
+	if err != nil {
+		log.WithError(err).Fatalln("Failed to create phone normalizer")
 	}
 
-	if err := run(ctx, log, conf); err != nil {
-		log.WithError(err).Fatalln("Program terminated unexpectedly.")
+	hasher, err := phone.NewHasher(conf.Phone.HashKey)
+	if err != nil {
+		log.WithError(err).Fatalln("Failed to create phone hasher")
 	}
-}
 
-func run(ctx context.Context, log *logrus.Entry, conf *config.Config) error {
-	connConf, err := pgx.ParseConfig(conf.Database.ToDataSourceName())
+	ring, err := keyring.New(conf.Encryption.PrimaryKey, conf.Encryption.Keys)
+	if err != nil {
+		log.WithError(err).Fatalln("Failed to create encryption key ring")
+	}
+
+	index, err := phone.NewHasher(conf.Encryption.IndexKey)
+	if err != nil {
+		log.WithError(err).Fatalln("Failed to create phone blind index")
+	}
+
+	if err := evolution(conf.Database.ToDataSourceName(), normalizer, hasher, index); err != nil {
+		log.WithError(err).Fatalln("Failed migrate")
+	}
+
+	poolConf, err := poolConfig(conf.Database)
 	if err != nil {
 		log.WithError(err).Fatalln("Failed to parse database connection string")
 	}
-	store, err := storage.New(ctx, connConf, log)
+	store, err := storage.New(ctx, poolConf, log, storage.WithPhoneEncryption(ring, index))
 	if err != nil {
 		log.WithError(err).Fatalln("Failed to create connection pool to database")
 	}
 	defer store.CloseFn(ctx)
 
-	svc := service.NewContactService(store)
+	if *encrypt {
+		updated, err := store.ReencryptPhones(ctx, 0)
+		if err != nil {
+			log.WithError(err).Fatalln("Failed to encrypt phone numbers")
+		}
+		log.Infof("Encrypted %d phone numbers", updated)
+		return
+	}
 
-	httpHandler := api.NewHTTPHandler(svc, log)
+	// номера, записанные открытым текстом до включения шифрования, не находятся по слепому индексу
+	encrypted, err := store.EncryptPlaintextPhones(ctx, 0)
+	if err != nil {
+		log.WithError(err).Fatalln("Failed to encrypt plaintext phone numbers")
+	}
+	if encrypted > 0 {
+		log.Infof("Encrypted %d plaintext phone numbers", encrypted)
+	}
 
-	router := gin.Default()
+	if err := run(ctx, log, conf, store, normalizer, hasher); err != nil {
+		log.WithError(err).Fatalln("Program terminated unexpectedly.")
+	}
+}
+
+func run(ctx context.Context, log *logrus.Entry, conf *config.Config, store *storage.Store, normalizer *phone.Normalizer, hasher *phone.Hasher) error {
+	sender, err := smsSender(conf, log)
+	if err != nil {
+		return fmt.Errorf("create sms sender: %w", err)
+	}
 
-	router.POST("/user/:userID/contact", httpHandler.AddContacts)
-	router.GET("/user/:userID/friends", httpHandler.Friends)
-	router.GET("/user/:userID/contact/name", httpHandler.Name)
+	svc := service.NewContactService(store, normalizer, hasher, events.NewLogPublisher(log), sender, log,
+		service.WithVerificationPolicy(service.VerificationPolicy{
+			CodeLength:     conf.Verification.CodeLength,
+			CodeTTL:        conf.Verification.CodeTTL,
+			MaxAttempts:    conf.Verification.MaxAttempts,
+			ResendInterval: conf.Verification.ResendInterval,
+		}))
+
+	authenticator, err := auth.NewAuthenticator(conf.Auth.Issuer, conf.Auth.Keys)
+	if err != nil {
+		return fmt.Errorf("create authenticator: %w", err)
+	}
+
+	httpHandler := api.NewHTTPHandler(svc, log, api.WithMaxContacts(conf.Contacts.MaxBatchSize))
+	debugHandler := api.NewDebugHandler(store)
+
+	router := gin.Default()
+	router.Use(api.RequestID())
+	authenticate := api.Authenticate(authenticator)
+
+	router.POST("/user", httpHandler.CreateUser)
+
+	user := router.Group("/user/:userID", authenticate, api.RequireUser("userID"))
+	user.GET("", httpHandler.GetUser)
+	user.PATCH("", httpHandler.UpdateUser)
+	user.DELETE("", httpHandler.DeleteUser)
+	user.GET("/export", httpHandler.ExportUser)
+
+	user.POST("/contact", httpHandler.AddContacts)
+	user.POST("/contact/hashed", httpHandler.AddHashedContacts)
+	user.DELETE("/contact", httpHandler.DeleteContacts)
+	user.DELETE("/contact/:relationUserID", httpHandler.DeleteContact)
+	user.PUT("/contacts", httpHandler.SyncContacts)
+	user.PATCH("/contacts", httpHandler.SyncContactsDelta)
+	user.GET("/friends", httpHandler.Friends)
+	user.GET("/friends/mutual", httpHandler.MutualFriends)
+	user.GET("/friends/common/:otherUserID", httpHandler.CommonFriends)
+	user.GET("/followers", httpHandler.Followers)
+	user.GET("/suggestions", httpHandler.Suggestions)
+	user.GET("/contact/name", httpHandler.Name)
+	user.POST("/phone/verify", httpHandler.RequestPhoneVerification)
+	user.POST("/phone/verify/confirm", httpHandler.ConfirmPhoneVerification)
+	user.GET("/privacy", httpHandler.GetPrivacy)
+	user.PUT("/privacy", httpHandler.UpdatePrivacy)
+	user.GET("/blocks", httpHandler.Blocks)
+	user.POST("/blocks", httpHandler.BlockUser)
+	user.DELETE("/blocks/:blockedUserID", httpHandler.UnblockUser)
+
+	debug := router.Group("/debug", authenticate, api.RequireAdmin())
+	debug.GET("/db/pool", debugHandler.PoolStat)
+
+	admin := router.Group("/admin", authenticate, api.RequireAdmin())
+	admin.GET("/audit", httpHandler.AuditEvents)
 
 	var g errgroup.Group
 
+	g.Go(func() error {
+		return svc.RunAuditRetention(ctx, conf.Audit.Retention, conf.Audit.PruneInterval)
+	})
+
 	g.Go(func() error {
 		log.Infof("Listening Phone service at %s", conf.ListenAddr)
 		return router.Run(conf.ListenAddr)
@@ -95,13 +194,61 @@ func run(ctx context.Context, log *logrus.Entry, conf *config.Config) error {
 	return g.Wait()
 }
 
-func evolution(dsn string) error {
+// smsSender способ доставки кодов подтверждения задается явно,
+// запись в лог без отправки допускается только в режиме dev
+func smsSender(conf *config.Config, log *logrus.Entry) (service.SMSSender, error) {
+	switch conf.Verification.Sender {
+	case "file":
+		if conf.Verification.SMSFile == "" {
+			return nil, errors.New("verification.smsFile is required for the file sender")
+		}
+		return sms.NewFileSender(conf.Verification.SMSFile), nil
+	case "log", "":
+		if !conf.Dev {
+			return nil, fmt.Errorf("verification sender %q is allowed only in dev mode", conf.Verification.Sender)
+		}
+		return sms.NewLogSender(log), nil
+	}
+
+	return nil, fmt.Errorf("unknown verification sender %q", conf.Verification.Sender)
+}
+
+func poolConfig(conf config.Database) (*pgxpool.Config, error) {
+	poolConf, err := pgxpool.ParseConfig(conf.ToDataSourceName())
+	if err != nil {
+		return nil, err
+	}
+
+	if conf.MaxConns > 0 {
+		poolConf.MaxConns = conf.MaxConns
+	}
+	if conf.MinConns > 0 {
+		poolConf.MinConns = conf.MinConns
+	}
+	if conf.MaxConnLifetime > 0 {
+		poolConf.MaxConnLifetime = conf.MaxConnLifetime
+	}
+	if conf.MaxConnIdleTime > 0 {
+		poolConf.MaxConnIdleTime = conf.MaxConnIdleTime
+	}
+	if conf.HealthCheckPeriod > 0 {
+		poolConf.HealthCheckPeriod = conf.HealthCheckPeriod
+	}
+
+	return poolConf, nil
+}
+
+func evolution(dsn string, normalizer *phone.Normalizer, hasher, index *phone.Hasher) error {
 	db, err := sql.Open("postgres", dsn)
 	if err != nil {
 		return err
 	}
 
-	tooling := migration.New(db)
+	tooling := migration.New(db,
+		migration.WithPhoneNormalizer(normalizer),
+		migration.WithPhoneHasher(hasher),
+		migration.WithPhoneIndex(index),
+	)
 
 	return tooling.Run()
 }
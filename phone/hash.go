@@ -0,0 +1,36 @@
+package phone
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+)
+
+var ErrEmptyHashKey = errors.New("phone hash key is empty")
+
+// HashLength длина хеша номера в hex
+const HashLength = sha256.Size * 2
+
+// Hasher вычисляет HMAC-SHA256 нормализованного номера в hex,
+// клиенты с тем же ключом считают такой же хеш для поиска контактов
+type Hasher struct {
+	key []byte
+}
+
+func NewHasher(key string) (*Hasher, error) {
+	if key == "" {
+		return nil, ErrEmptyHashKey
+	}
+
+	return &Hasher{
+		key: []byte(key),
+	}, nil
+}
+
+// Hash number должен быть нормализован Normalizer
+func (h *Hasher) Hash(number string) string {
+	mac := hmac.New(sha256.New, h.key)
+	mac.Write([]byte(number))
+	return hex.EncodeToString(mac.Sum(nil))
+}
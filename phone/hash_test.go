@@ -0,0 +1,34 @@
+package phone
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestHasher_Hash(t *testing.T) {
+	h, err := NewHasher("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// printf '+79831234567' | openssl dgst -sha256 -hmac secret
+	want := "001c2b4133accd81504b852f2cdc19af51ccd1915e3ff94d5fb8307c4246e0b2"
+	got := h.Hash("+79831234567")
+	if got != want {
+		t.Fatalf("ожидался %q, получен %q", want, got)
+	}
+
+	other, err := NewHasher("other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == other.Hash("+79831234567") {
+		t.Fatal("хеш должен зависеть от ключа")
+	}
+}
+
+func TestNewHasher_EmptyKey(t *testing.T) {
+	if _, err := NewHasher(""); !errors.Is(err, ErrEmptyHashKey) {
+		t.Fatalf("ожидалась ErrEmptyHashKey, получено %v", err)
+	}
+}
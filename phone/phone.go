@@ -0,0 +1,87 @@
+package phone
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const DefaultRegion = "RU"
+
+var ErrInvalidNumber = errors.New("invalid phone number")
+
+type region struct {
+	countryCode    string
+	trunkPrefix    string
+	nationalLength int
+}
+
+// regions правила набора национальных номеров для поддерживаемых регионов
+var regions = map[string]region{
+	"RU": {countryCode: "7", trunkPrefix: "8", nationalLength: 10},
+	"KZ": {countryCode: "7", trunkPrefix: "8", nationalLength: 10},
+	"BY": {countryCode: "375", trunkPrefix: "80", nationalLength: 9},
+	"UA": {countryCode: "380", trunkPrefix: "0", nationalLength: 9},
+	"US": {countryCode: "1", trunkPrefix: "1", nationalLength: 10},
+	"GB": {countryCode: "44", trunkPrefix: "0", nationalLength: 10},
+}
+
+const (
+	minE164Digits = 8
+	maxE164Digits = 15
+)
+
+// Normalizer приводит номера телефонов к формату E.164,
+// номера без кода страны считаются номерами региона по умолчанию
+type Normalizer struct {
+	region region
+}
+
+func NewNormalizer(defaultRegion string) (*Normalizer, error) {
+	r, ok := regions[strings.ToUpper(defaultRegion)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported phone region %q", defaultRegion)
+	}
+	return &Normalizer{region: r}, nil
+}
+
+// Normalize кроме цифр допускает "+" в начале номера, пробелы, скобки, дефисы и точки,
+// номера с любыми другими символами отклоняются
+func (n *Normalizer) Normalize(number string) (string, error) {
+	number = strings.TrimSpace(number)
+	international := strings.HasPrefix(number, "+")
+
+	digits := make([]byte, 0, len(number))
+	for i := 0; i < len(number); i++ {
+		switch c := number[i]; {
+		case c >= '0' && c <= '9':
+			digits = append(digits, c)
+		case c == ' ', c == '(', c == ')', c == '-', c == '.':
+		case c == '+' && i == 0:
+		default:
+			return "", fmt.Errorf("%w: %q", ErrInvalidNumber, number)
+		}
+	}
+	national := string(digits)
+
+	switch {
+	case international:
+	case strings.HasPrefix(national, "00"):
+		national = national[2:]
+	case len(national) == n.region.nationalLength:
+		national = n.region.countryCode + national
+	case len(national) == len(n.region.trunkPrefix)+n.region.nationalLength &&
+		strings.HasPrefix(national, n.region.trunkPrefix):
+		national = n.region.countryCode + national[len(n.region.trunkPrefix):]
+	case len(national) == len(n.region.countryCode)+n.region.nationalLength &&
+		strings.HasPrefix(national, n.region.countryCode):
+	default:
+		return "", fmt.Errorf("%w: %q", ErrInvalidNumber, number)
+	}
+
+	if len(national) < minE164Digits || len(national) > maxE164Digits || national[0] == '0' {
+		return "", fmt.Errorf("%w: %q", ErrInvalidNumber, number)
+	}
+
+	return "+" + national, nil
+}
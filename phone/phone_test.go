@@ -0,0 +1,78 @@
+package phone
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizer_Normalize(t *testing.T) {
+	n, err := NewNormalizer("RU")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{"+7 (983) 123-45-67", "+79831234567"},
+		{"89831234567", "+79831234567"},
+		{"+79831234567", "+79831234567"},
+		{"79831234567", "+79831234567"},
+		{"9831234567", "+79831234567"},
+		{"8 983 123 45 67", "+79831234567"},
+		{"8.983.123.45.67", "+79831234567"},
+		{"00375291234567", "+375291234567"},
+		{"+44 20 7946 0958", "+442079460958"},
+	}
+
+	for _, c := range cases {
+		got, err := n.Normalize(c.in)
+		if err != nil {
+			t.Fatalf("%q: %v", c.in, err)
+		}
+		if got != c.want {
+			t.Fatalf("%q: ожидался %q, получен %q", c.in, c.want, got)
+		}
+	}
+}
+
+func TestNormalizer_NormalizeInvalid(t *testing.T) {
+	n, err := NewNormalizer("RU")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name string
+		in   string
+	}{
+		{"пустой", ""},
+		{"короткий", "123"},
+		{"короткий международный", "+7983"},
+		{"только буквы", "abc"},
+		{"ведущий ноль", "+0123456789"},
+		{"буквы внутри номера", "8 983 abc 000-00-04"},
+		{"буква в конце", "+79831234567x"},
+		{"кириллица", "8 983 123 45 67 доб"},
+		{"плюс не в начале", "7+9831234567"},
+		{"два плюса", "++79831234567"},
+		{"слеш", "8/983/123-45-67"},
+		{"табуляция внутри", "8\t9831234567"},
+		{"звездочка", "*79831234567"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := n.Normalize(c.in); !errors.Is(err, ErrInvalidNumber) {
+				t.Fatalf("%q: ожидалась ошибка ErrInvalidNumber, получено %v", c.in, err)
+			}
+		})
+	}
+}
+
+func TestNewNormalizer_UnknownRegion(t *testing.T) {
+	if _, err := NewNormalizer("XX"); err == nil {
+		t.Fatal("ожидалась ошибка для неизвестного региона")
+	}
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"integ/entities"
+	"integ/storage"
+	"strconv"
+	"time"
+)
+
+type (
+	actorKey     struct{}
+	requestIDKey struct{}
+)
+
+// auditActor инициатор запроса из токена
+type auditActor struct {
+	subject string
+	role    string
+}
+
+// WithActor запоминает в контексте subject и роль токена, от имени которого выполняется запрос
+func WithActor(ctx context.Context, subject, role string) context.Context {
+	return context.WithValue(ctx, actorKey{}, auditActor{subject: subject, role: role})
+}
+
+// WithRequestID запоминает в контексте идентификатор запроса для журнала аудита
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// audit записывает событие журнала, инициатор и идентификатор запроса берутся из контекста
+func (c *ContactService) audit(ctx context.Context, action entities.AuditAction, targetUserID, count int) error {
+	event := &entities.AuditEvent{
+		Action:       action,
+		TargetUserID: targetUserID,
+		Count:        count,
+	}
+	if actor, ok := ctx.Value(actorKey{}).(auditActor); ok {
+		event.ActorSubject = actor.subject
+		event.ActorRole = actor.role
+		// у администраторов subject может быть не идентификатором пользователя
+		if userID, err := strconv.Atoi(actor.subject); err == nil {
+			event.ActorUserID = &userID
+		}
+	}
+	if requestID, ok := ctx.Value(requestIDKey{}).(string); ok {
+		event.RequestID = requestID
+	}
+
+	return c.store.SaveAuditEvent(ctx, event)
+}
+
+// FindAuditEvents страница журнала аудита от новых событий к старым
+func (c *ContactService) FindAuditEvents(ctx context.Context, query storage.AuditQuery) (*entities.AuditPage, error) {
+	// запрашиваем на одну строку больше, чтобы понять, есть ли следующая страница
+	limit := query.Limit
+	if limit > 0 {
+		query.Limit = limit + 1
+	}
+
+	events, err := c.store.FindAuditEvents(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &entities.AuditPage{}
+	if limit > 0 && uint(len(events)) > limit {
+		events = events[:limit]
+		page.NextCursor = strconv.FormatInt(events[len(events)-1].EventID, 10)
+	}
+	page.Events = events
+
+	return page, nil
+}
+
+// RunAuditRetention раз в interval удаляет события журнала старше retention, пока не отменен ctx,
+// нулевой retention отключает удаление
+func (c *ContactService) RunAuditRetention(ctx context.Context, retention, interval time.Duration) error {
+	if retention <= 0 || interval <= 0 {
+		c.log.Warnln("Audit events retention is disabled")
+		return nil
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		pruned, err := c.store.PruneAuditEvents(ctx, time.Now().Add(-retention))
+		if err != nil {
+			// следующая попытка через interval
+			c.log.WithError(err).Errorln("Failed to prune audit events")
+		} else if pruned > 0 {
+			c.log.Infof("Pruned %d audit events older than %s", pruned, retention)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
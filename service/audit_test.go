@@ -0,0 +1,34 @@
+package service
+
+import (
+	"context"
+	"integ/entities"
+	"testing"
+)
+
+func TestContactService_AuditActor(t *testing.T) {
+	store := newStoreMock(nil)
+	svc := newTestService(t, store)
+
+	ctx := WithRequestID(context.Background(), "req-1")
+	if err := svc.audit(WithActor(ctx, "42", ""), entities.AuditGetName, 7, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.audit(WithActor(ctx, "ops@example.com", "admin"), entities.AuditFindFriends, 7, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(store.events) != 2 {
+		t.Fatalf("ожидалось 2 события, получено %d", len(store.events))
+	}
+
+	user := store.events[0]
+	if user.ActorUserID == nil || *user.ActorUserID != 42 || user.ActorSubject != "42" || user.RequestID != "req-1" {
+		t.Fatalf("событие пользователя должно содержать его идентификатор, получено %+v", user)
+	}
+
+	admin := store.events[1]
+	if admin.ActorUserID != nil || admin.ActorSubject != "ops@example.com" || admin.ActorRole != "admin" {
+		t.Fatalf("событие администратора должно содержать subject и роль, получено %+v", admin)
+	}
+}
@@ -2,82 +2,285 @@ package service
 
 import (
 	"context"
+	"errors"
 	"integ/entities"
+	"integ/phone"
 	"integ/storage"
+	"strconv"
+	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/sirupsen/logrus"
 )
 
 type ContactStore interface {
-	FindUserByPhone(ctx context.Context, number string) (*entities.User, error)
-	SaveRelation(ctx context.Context, relation *entities.Relation) error
-	FindFriends(ctx context.Context, uid int) ([]*entities.Friend, error)
+	FindUsersByPhones(ctx context.Context, numbers []string) (map[string]*entities.User, error)
+	FindDiscoverableUsersByPhones(ctx context.Context, uploader int, numbers []string) (map[string]*entities.User, error)
+	FindDiscoverableUsersByHashes(ctx context.Context, uploader int, hashes []string) (map[string]*entities.User, error)
+	SaveRelations(ctx context.Context, relations entities.RelationList) (int, error)
+	FindFriends(ctx context.Context, uid int, query storage.FriendsQuery) ([]*entities.Friend, error)
+	FindMutualFriends(ctx context.Context, uid int) ([]*entities.Friend, error)
+	FindCommonFriends(ctx context.Context, uid, otherUID int) ([]*entities.Friend, error)
+	FindFollowers(ctx context.Context, uid int) ([]*entities.Follower, error)
+	FindSuggestions(ctx context.Context, uid int, limit, offset uint) ([]*entities.Suggestion, error)
 	GetName(ctx context.Context, uid int) (string, error)
-	storage.DB
+	CreateUser(ctx context.Context, user *entities.User) (*entities.User, error)
+	GetUser(ctx context.Context, uid int) (*entities.User, error)
+	UpdateUser(ctx context.Context, uid int, update entities.UserUpdate) (*entities.User, error)
+	DeleteUser(ctx context.Context, uid int) error
+	EraseUser(ctx context.Context, uid int) error
+	ExportUser(ctx context.Context, uid int) (*entities.UserExport, error)
+	SavePendingContacts(ctx context.Context, contacts []*entities.PendingContact) error
+	MaterializePendingContacts(ctx context.Context, user *entities.User) (entities.RelationList, error)
+	DeleteRelation(ctx context.Context, uid, relationUserID int) error
+	DeleteRelations(ctx context.Context, uid int, relationUserIDs []int) (int, error)
+	DeleteRelationsExcept(ctx context.Context, uid int, keep []int) (int, error)
+	DeletePendingContacts(ctx context.Context, uid int, numbers []string) error
+	DeletePendingContactsExcept(ctx context.Context, uid int, keep []string) error
+	NextSyncVersion(ctx context.Context, uid int) (int64, error)
+	AdvanceSyncVersion(ctx context.Context, uid int, expected int64) (int64, bool, error)
+	SavePhoneVerification(ctx context.Context, verification *entities.PhoneVerification) error
+	GetPhoneVerification(ctx context.Context, uid int) (*entities.PhoneVerification, error)
+	IncrementVerificationAttempts(ctx context.Context, uid int) error
+	DeletePhoneVerification(ctx context.Context, uid int) error
+	MarkPhoneVerified(ctx context.Context, uid int, number string) (*entities.User, error)
+	GetPrivacy(ctx context.Context, uid int) (*entities.PrivacySettings, error)
+	UpdatePrivacy(ctx context.Context, uid int, settings entities.PrivacySettings) (*entities.PrivacySettings, error)
+	BlockUser(ctx context.Context, uid, blockedUID int) error
+	UnblockUser(ctx context.Context, uid, blockedUID int) error
+	FindBlockedUsers(ctx context.Context, uid int) ([]*entities.BlockedUser, error)
+	SaveAuditEvent(ctx context.Context, event *entities.AuditEvent) error
+	FindAuditEvents(ctx context.Context, query storage.AuditQuery) ([]*entities.AuditEvent, error)
+	PruneAuditEvents(ctx context.Context, before time.Time) (int, error)
+	WithinTx(ctx context.Context, opts pgx.TxOptions, fn func(ctx context.Context) error) error
+}
+
+type EventPublisher interface {
+	Publish(ctx context.Context, event entities.Event) error
 }
 
 type ContactService struct {
-	store ContactStore
+	store        ContactStore
+	normalizer   *phone.Normalizer
+	hasher       *phone.Hasher
+	events       EventPublisher
+	sms          SMSSender
+	verification VerificationPolicy
+	log          logrus.FieldLogger
 }
 
-func NewContactService(store ContactStore) *ContactService {
-	return &ContactService{store: store}
+func NewContactService(store ContactStore, normalizer *phone.Normalizer, hasher *phone.Hasher, events EventPublisher, sms SMSSender, log logrus.FieldLogger, opts ...func(*ContactService)) *ContactService {
+	svc := &ContactService{
+		store:        store,
+		normalizer:   normalizer,
+		hasher:       hasher,
+		events:       events,
+		sms:          sms,
+		verification: DefaultVerificationPolicy,
+		log:          log,
+	}
+	for _, opt := range opts {
+		opt(svc)
+	}
+	return svc
 }
 
-func (c *ContactService) SaveContacts(ctx context.Context, userID int, contacts entities.ContactList) error {
-	relations := make(entities.RelationList, 0)
+func (c *ContactService) SaveContacts(ctx context.Context, userID int, contacts entities.ContactList) (*entities.SaveContactsResult, error) {
+	numbers, names, rejected := c.normalizeContacts(contacts)
+
+	var linked *linkedContacts
+	err := c.store.WithinTx(ctx, pgx.TxOptions{}, func(ctx context.Context) error {
+		var err error
+		linked, err = c.linkContacts(ctx, userID, numbers, names)
+		if err != nil {
+			return err
+		}
 
-	tx, err := c.store.StartTX(ctx, pgx.TxOptions{})
+		return c.audit(ctx, entities.AuditSaveContacts, userID, len(contacts))
+	})
 	if err != nil {
-		return nil
+		return nil, err
 	}
 
-	ctx = context.WithValue(ctx, "tx", &tx)
+	result := linked.result()
+	result.Rejected = rejected
 
-	for _, contact := range contacts {
-		user, err := c.store.FindUserByPhone(ctx, contact.PhoneNumber)
-		if err != nil {
-			return err
+	return result, nil
+}
+
+// normalizeContacts возвращает уникальные нормализованные номера в порядке загрузки
+// и имена контактов по номеру, контакты, не прошедшие проверку или нормализацию,
+// возвращаются в rejected
+func (c *ContactService) normalizeContacts(contacts entities.ContactList) ([]string, map[string]string, []entities.ContactRejection) {
+	numbers := make([]string, 0, len(contacts))
+	names := make(map[string]string, len(contacts))
+	var rejected []entities.ContactRejection
+	for i, contact := range contacts {
+		if err := contact.Validate(); err != nil {
+			rejected = append(rejected, entities.ContactRejection{Index: i, Reason: err.Error()})
+			continue
 		}
-		if user == nil {
+
+		number, err := c.normalizer.Normalize(contact.PhoneNumber)
+		if errors.Is(err, phone.ErrInvalidNumber) {
+			rejected = append(rejected, entities.ContactRejection{Index: i, Reason: phone.ErrInvalidNumber.Error()})
+			continue
+		}
+		if _, ok := names[number]; ok {
+			continue
+		}
+		names[number] = contact.Name
+		numbers = append(numbers, number)
+	}
+
+	return numbers, names, rejected
+}
+
+type linkedContacts struct {
+	relations entities.RelationList
+	pending   []string
+	created   int
+}
+
+func (l *linkedContacts) result() *entities.SaveContactsResult {
+	return &entities.SaveContactsResult{
+		Created:  l.created,
+		Existing: len(l.relations) - l.created,
+	}
+}
+
+// linkContacts сохраняет связи с найденными пользователями и отложенные контакты
+// для остальных номеров, вызывается внутри транзакции. Пользователи, скрытые настройками
+// обнаружения или блокировкой, попадают в отложенные и не раскрываются
+func (c *ContactService) linkContacts(ctx context.Context, userID int, numbers []string, names map[string]string) (*linkedContacts, error) {
+	users, err := c.store.FindDiscoverableUsersByPhones(ctx, userID, numbers)
+	if err != nil {
+		return nil, err
+	}
+
+	linked := &linkedContacts{
+		relations: make(entities.RelationList, 0, len(users)),
+		pending:   make([]string, 0),
+	}
+	pending := make([]*entities.PendingContact, 0)
+	for _, number := range numbers {
+		user, ok := users[number]
+		if !ok {
+			pending = append(pending, &entities.PendingContact{
+				UserID:      userID,
+				PhoneNumber: number,
+				ContactName: names[number],
+			})
+			linked.pending = append(linked.pending, number)
 			continue
 		}
 
-		relations = append(relations, &entities.Relation{
+		linked.relations = append(linked.relations, &entities.Relation{
 			UserID:         userID,
 			RelationUserID: user.UserID,
+			ContactName:    names[number],
 		})
 	}
 
-	for _, relation := range relations {
+	if err := c.store.SavePendingContacts(ctx, pending); err != nil {
+		return nil, err
+	}
+
+	linked.created, err = c.store.SaveRelations(ctx, linked.relations)
+	if err != nil {
+		return nil, err
+	}
+
+	return linked, nil
+}
+
+func (c *ContactService) DeleteContact(ctx context.Context, userID, relationUserID int) error {
+	return c.store.DeleteRelation(ctx, userID, relationUserID)
+}
 
-		err := c.store.SaveRelation(ctx, relation)
+func (c *ContactService) DeleteContacts(ctx context.Context, userID int, relationUserIDs []int) (int, error) {
+	return c.store.DeleteRelations(ctx, userID, relationUserIDs)
+}
+
+func (c *ContactService) FindFriends(ctx context.Context, userID int, query storage.FriendsQuery) (*entities.FriendsPage, error) {
+	if query.PhoneNumber != "" {
+		number, err := c.normalizer.Normalize(query.PhoneNumber)
 		if err != nil {
-			return err
+			return nil, err
 		}
+		query.PhoneNumber = number
 	}
 
-	err = c.store.EndTX(ctx, tx)
+	// запрашиваем на одну строку больше, чтобы понять, есть ли следующая страница
+	limit := query.Limit
+	if limit > 0 {
+		query.Limit = limit + 1
+	}
+
+	resp, err := c.store.FindFriends(ctx, userID, query)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	page := &entities.FriendsPage{}
+	if limit > 0 && uint(len(resp)) > limit {
+		resp = resp[:limit]
+		page.NextCursor = strconv.Itoa(resp[len(resp)-1].RelationID)
+	}
+	page.Friends = toFriendsList(resp)
+
+	// чтение без записи в журнал аудита не отдается
+	if err := c.audit(ctx, entities.AuditFindFriends, userID, len(page.Friends)); err != nil {
+		return nil, err
 	}
 
-	return nil
+	return page, nil
 }
 
-func (c *ContactService) FindFriends(ctx context.Context, userID int) (entities.FriendsList, error) {
-	friends := make(entities.FriendsList, 0)
+func (c *ContactService) FindMutualFriends(ctx context.Context, userID int) (entities.FriendsList, error) {
+	resp, err := c.store.FindMutualFriends(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
 
-	resp, err := c.store.FindFriends(ctx, userID)
+	return toFriendsList(resp), nil
+}
+
+func (c *ContactService) FindCommonFriends(ctx context.Context, userID, otherUserID int) (entities.FriendsList, error) {
+	resp, err := c.store.FindCommonFriends(ctx, userID, otherUserID)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, f := range resp {
-		friends = append(friends, *f)
+	return toFriendsList(resp), nil
+}
+
+func (c *ContactService) FindFollowers(ctx context.Context, userID int) (entities.FollowerList, error) {
+	resp, err := c.store.FindFollowers(ctx, userID)
+	if err != nil {
+		return nil, err
 	}
 
-	return friends, nil
+	followers := make(entities.FollowerList, 0, len(resp))
+	for _, u := range resp {
+		followers = append(followers, *u)
+	}
+
+	return followers, nil
+}
+
+func (c *ContactService) FindSuggestions(ctx context.Context, userID int, limit, offset uint) (entities.SuggestionList, error) {
+	resp, err := c.store.FindSuggestions(ctx, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	suggestions := make(entities.SuggestionList, 0, len(resp))
+	for _, s := range resp {
+		suggestions = append(suggestions, *s)
+	}
+
+	return suggestions, nil
 }
 
 func (c *ContactService) GetName(ctx context.Context, userID int) (string, error) {
@@ -87,5 +290,86 @@ func (c *ContactService) GetName(ctx context.Context, userID int) (string, error
 		return "", err
 	}
 
+	if err := c.audit(ctx, entities.AuditGetName, userID, 1); err != nil {
+		return "", err
+	}
+
 	return resp, nil
 }
+
+// CreateUser регистрирует пользователя, номер участвует в сопоставлении контактов
+// только после подтверждения через ConfirmPhoneVerification
+func (c *ContactService) CreateUser(ctx context.Context, user *entities.User) (*entities.User, error) {
+	number, err := c.normalizer.Normalize(user.PhoneNumber)
+	if err != nil {
+		return nil, err
+	}
+	user.PhoneNumber = number
+	user.PhoneHash = c.hasher.Hash(number)
+
+	return c.store.CreateUser(ctx, user)
+}
+
+func (c *ContactService) GetUser(ctx context.Context, userID int) (*entities.User, error) {
+	return c.store.GetUser(ctx, userID)
+}
+
+// UpdateUser обновляет профиль, при смене номера подтверждение сбрасывается
+func (c *ContactService) UpdateUser(ctx context.Context, userID int, update entities.UserUpdate) (*entities.User, error) {
+	if update.PhoneNumber != nil {
+		number, err := c.normalizer.Normalize(*update.PhoneNumber)
+		if err != nil {
+			return nil, err
+		}
+		hash := c.hasher.Hash(number)
+		update.PhoneNumber = &number
+		update.PhoneHash = &hash
+	}
+
+	var user *entities.User
+	err := c.store.WithinTx(ctx, pgx.TxOptions{}, func(ctx context.Context) error {
+		var err error
+		user, err = c.store.UpdateUser(ctx, userID, update)
+		if err != nil || update.PhoneNumber == nil {
+			return err
+		}
+
+		return c.store.DeletePhoneVerification(ctx, userID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// DeleteUser удаляет пользователя и все связи от него и к нему
+func (c *ContactService) DeleteUser(ctx context.Context, userID int) error {
+	return c.store.WithinTx(ctx, pgx.TxOptions{}, func(ctx context.Context) error {
+		return c.store.DeleteUser(ctx, userID)
+	})
+}
+
+// publishContactsJoined уведомляет загрузивших контакт о регистрации,
+// ошибка публикации не отменяет уже созданные связи
+func (c *ContactService) publishContactsJoined(ctx context.Context, relations entities.RelationList) {
+	for _, relation := range relations {
+		err := c.events.Publish(ctx, entities.ContactJoinedEvent{
+			UserID:        relation.UserID,
+			ContactUserID: relation.RelationUserID,
+			ContactName:   relation.ContactName,
+		})
+		if err != nil {
+			c.log.WithError(err).Error("Failed to publish contact joined event")
+		}
+	}
+}
+
+func toFriendsList(resp []*entities.Friend) entities.FriendsList {
+	friends := make(entities.FriendsList, 0, len(resp))
+	for _, f := range resp {
+		friends = append(friends, *f)
+	}
+
+	return friends
+}
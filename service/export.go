@@ -0,0 +1,31 @@
+package service
+
+import (
+	"context"
+	"integ/entities"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ExportUser выгрузка всех данных пользователя из одного снимка базы
+func (c *ContactService) ExportUser(ctx context.Context, userID int) (*entities.UserExport, error) {
+	var export *entities.UserExport
+	err := c.store.WithinTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly}, func(ctx context.Context) error {
+		var err error
+		export, err = c.store.ExportUser(ctx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
+
+// EraseUser удаляет пользователя вместе со всеми связями от него и к нему,
+// от пользователя остается обезличенная запись
+func (c *ContactService) EraseUser(ctx context.Context, userID int) error {
+	return c.store.WithinTx(ctx, pgx.TxOptions{}, func(ctx context.Context) error {
+		return c.store.EraseUser(ctx, userID)
+	})
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"integ/entities"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// SaveHashedContacts связывает пользователя с контактами, загруженными в виде хешей номеров,
+// хеши незарегистрированных номеров не сохраняются
+func (c *ContactService) SaveHashedContacts(ctx context.Context, userID int, contacts entities.HashedContactList) (*entities.SaveContactsResult, error) {
+	hashes := make([]string, 0, len(contacts))
+	names := make(map[string]string, len(contacts))
+	var rejected []entities.ContactRejection
+	for i, contact := range contacts {
+		if err := contact.Validate(); err != nil {
+			rejected = append(rejected, entities.ContactRejection{Index: i, Reason: err.Error()})
+			continue
+		}
+
+		hash := strings.ToLower(contact.PhoneHash)
+		if _, ok := names[hash]; ok {
+			continue
+		}
+		names[hash] = contact.Name
+		hashes = append(hashes, hash)
+	}
+
+	var linked *linkedContacts
+	err := c.store.WithinTx(ctx, pgx.TxOptions{}, func(ctx context.Context) error {
+		users, err := c.store.FindDiscoverableUsersByHashes(ctx, userID, hashes)
+		if err != nil {
+			return err
+		}
+
+		linked = &linkedContacts{relations: make(entities.RelationList, 0, len(users))}
+		for _, hash := range hashes {
+			user, ok := users[hash]
+			if !ok {
+				continue
+			}
+			linked.relations = append(linked.relations, &entities.Relation{
+				UserID:         userID,
+				RelationUserID: user.UserID,
+				ContactName:    names[hash],
+			})
+		}
+
+		linked.created, err = c.store.SaveRelations(ctx, linked.relations)
+		if err != nil {
+			return err
+		}
+
+		return c.audit(ctx, entities.AuditSaveHashedContacts, userID, len(contacts))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := linked.result()
+	result.Rejected = rejected
+
+	return result, nil
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"integ/entities"
+	"integ/storage"
+)
+
+func (c *ContactService) GetPrivacy(ctx context.Context, userID int) (*entities.PrivacySettings, error) {
+	return c.store.GetPrivacy(ctx, userID)
+}
+
+func (c *ContactService) UpdatePrivacy(ctx context.Context, userID int, settings entities.PrivacySettings) (*entities.PrivacySettings, error) {
+	switch settings.Discoverability {
+	case entities.DiscoverableEveryone, entities.DiscoverableContacts, entities.DiscoverableNobody:
+	default:
+		return nil, fmt.Errorf("unknown discoverability %q: %w", settings.Discoverability, storage.ErrInvalid)
+	}
+
+	return c.store.UpdatePrivacy(ctx, userID, settings)
+}
+
+// BlockUser скрывает пользователей друг от друга в списках друзей, подписчиков и рекомендаций
+// и запрещает связывать их при загрузке контактов
+func (c *ContactService) BlockUser(ctx context.Context, userID, blockedUserID int) error {
+	if userID == blockedUserID {
+		return fmt.Errorf("user %d cannot block themselves: %w", userID, storage.ErrInvalid)
+	}
+
+	if _, err := c.store.GetUser(ctx, blockedUserID); err != nil {
+		return err
+	}
+
+	return c.store.BlockUser(ctx, userID, blockedUserID)
+}
+
+func (c *ContactService) UnblockUser(ctx context.Context, userID, blockedUserID int) error {
+	return c.store.UnblockUser(ctx, userID, blockedUserID)
+}
+
+func (c *ContactService) FindBlockedUsers(ctx context.Context, userID int) (entities.BlockedUserList, error) {
+	resp, err := c.store.FindBlockedUsers(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	blocked := make(entities.BlockedUserList, 0, len(resp))
+	for _, b := range resp {
+		blocked = append(blocked, *b)
+	}
+
+	return blocked, nil
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"integ/entities"
+	"integ/phone"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrSyncTokenMismatch токен синхронизации устарел, клиенту нужна полная синхронизация
+var ErrSyncTokenMismatch = errors.New("sync token mismatch")
+
+// SyncContacts принимает полную адресную книгу: добавляет новые контакты
+// и удаляет связи и отложенные контакты, которых в ней больше нет
+func (c *ContactService) SyncContacts(ctx context.Context, userID int, contacts entities.ContactList) (*entities.SyncContactsResult, error) {
+	numbers, names, rejected := c.normalizeContacts(contacts)
+
+	var result *entities.SyncContactsResult
+	err := c.store.WithinTx(ctx, pgx.TxOptions{}, func(ctx context.Context) error {
+		linked, err := c.linkContacts(ctx, userID, numbers, names)
+		if err != nil {
+			return err
+		}
+
+		keep := make([]int, 0, len(linked.relations))
+		for _, relation := range linked.relations {
+			keep = append(keep, relation.RelationUserID)
+		}
+
+		removed, err := c.store.DeleteRelationsExcept(ctx, userID, keep)
+		if err != nil {
+			return err
+		}
+
+		if err := c.store.DeletePendingContactsExcept(ctx, userID, linked.pending); err != nil {
+			return err
+		}
+
+		version, err := c.store.NextSyncVersion(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		result = &entities.SyncContactsResult{
+			SaveContactsResult: *linked.result(),
+			Removed:            removed,
+			SyncToken:          strconv.FormatInt(version, 10),
+		}
+		result.Rejected = rejected
+		return c.audit(ctx, entities.AuditSyncContacts, userID, len(contacts))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// SyncContactsDelta применяет изменения адресной книги, сделанные после синхронизации delta.SyncToken,
+// сначала удаления, затем добавления
+func (c *ContactService) SyncContactsDelta(ctx context.Context, userID int, delta entities.ContactsDelta) (*entities.SyncContactsResult, error) {
+	expected, err := strconv.ParseInt(delta.SyncToken, 10, 64)
+	if err != nil {
+		return nil, ErrSyncTokenMismatch
+	}
+
+	added, names, rejected := c.normalizeContacts(delta.Added)
+	removedNumbers, removedRejected := c.normalizeNumbers(delta.Removed)
+	rejected = append(rejected, removedRejected...)
+
+	var result *entities.SyncContactsResult
+	err = c.store.WithinTx(ctx, pgx.TxOptions{}, func(ctx context.Context) error {
+		version, ok, err := c.store.AdvanceSyncVersion(ctx, userID, expected)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrSyncTokenMismatch
+		}
+
+		users, err := c.store.FindUsersByPhones(ctx, removedNumbers)
+		if err != nil {
+			return err
+		}
+
+		relationUserIDs := make([]int, 0, len(users))
+		for _, user := range users {
+			relationUserIDs = append(relationUserIDs, user.UserID)
+		}
+
+		removed, err := c.store.DeleteRelations(ctx, userID, relationUserIDs)
+		if err != nil {
+			return err
+		}
+
+		if err := c.store.DeletePendingContacts(ctx, userID, removedNumbers); err != nil {
+			return err
+		}
+
+		linked, err := c.linkContacts(ctx, userID, added, names)
+		if err != nil {
+			return err
+		}
+
+		result = &entities.SyncContactsResult{
+			SaveContactsResult: *linked.result(),
+			Removed:            removed,
+			SyncToken:          strconv.FormatInt(version, 10),
+		}
+		result.Rejected = rejected
+		return c.audit(ctx, entities.AuditSyncContactsDelta, userID, len(delta.Added)+len(delta.Removed))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// normalizeNumbers нормализует номера удаляемых контактов, имена не проверяются,
+// отклоненные номера помечаются списком removed
+func (c *ContactService) normalizeNumbers(contacts entities.ContactList) ([]string, []entities.ContactRejection) {
+	numbers := make([]string, 0, len(contacts))
+	seen := make(map[string]bool, len(contacts))
+	var rejected []entities.ContactRejection
+	for i, contact := range contacts {
+		number, err := c.normalizer.Normalize(contact.PhoneNumber)
+		if err != nil {
+			rejected = append(rejected, entities.ContactRejection{Index: i, List: "removed", Reason: phone.ErrInvalidNumber.Error()})
+			continue
+		}
+		if seen[number] {
+			continue
+		}
+		seen[number] = true
+		numbers = append(numbers, number)
+	}
+
+	return numbers, rejected
+}
@@ -0,0 +1,256 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"integ/entities"
+	"integ/phone"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// storeMock хранит адресные книги в памяти, методы, не нужные синхронизации, не реализованы
+type storeMock struct {
+	ContactStore
+
+	users     map[string]int
+	relations map[int]map[int]string
+	pending   map[int]map[string]string
+	versions  map[int]int64
+	events    []*entities.AuditEvent
+}
+
+func newStoreMock(users map[string]int) *storeMock {
+	return &storeMock{
+		users:     users,
+		relations: make(map[int]map[int]string),
+		pending:   make(map[int]map[string]string),
+		versions:  make(map[int]int64),
+	}
+}
+
+func (s *storeMock) WithinTx(ctx context.Context, opts pgx.TxOptions, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func (s *storeMock) FindUsersByPhones(ctx context.Context, numbers []string) (map[string]*entities.User, error) {
+	found := make(map[string]*entities.User)
+	for _, number := range numbers {
+		if userID, ok := s.users[number]; ok {
+			found[number] = &entities.User{UserID: userID, PhoneNumber: number}
+		}
+	}
+	return found, nil
+}
+
+func (s *storeMock) FindDiscoverableUsersByPhones(ctx context.Context, uploader int, numbers []string) (map[string]*entities.User, error) {
+	return s.FindUsersByPhones(ctx, numbers)
+}
+
+func (s *storeMock) SaveRelations(ctx context.Context, relations entities.RelationList) (int, error) {
+	created := 0
+	for _, relation := range relations {
+		if s.relations[relation.UserID] == nil {
+			s.relations[relation.UserID] = make(map[int]string)
+		}
+		if _, ok := s.relations[relation.UserID][relation.RelationUserID]; !ok {
+			created++
+		}
+		s.relations[relation.UserID][relation.RelationUserID] = relation.ContactName
+	}
+	return created, nil
+}
+
+func (s *storeMock) SavePendingContacts(ctx context.Context, contacts []*entities.PendingContact) error {
+	for _, contact := range contacts {
+		if s.pending[contact.UserID] == nil {
+			s.pending[contact.UserID] = make(map[string]string)
+		}
+		s.pending[contact.UserID][contact.PhoneNumber] = contact.ContactName
+	}
+	return nil
+}
+
+func (s *storeMock) DeleteRelations(ctx context.Context, uid int, relationUserIDs []int) (int, error) {
+	removed := 0
+	for _, relationUserID := range relationUserIDs {
+		if _, ok := s.relations[uid][relationUserID]; ok {
+			delete(s.relations[uid], relationUserID)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+func (s *storeMock) DeleteRelationsExcept(ctx context.Context, uid int, keep []int) (int, error) {
+	kept := make(map[int]bool, len(keep))
+	for _, relationUserID := range keep {
+		kept[relationUserID] = true
+	}
+
+	removed := make([]int, 0)
+	for relationUserID := range s.relations[uid] {
+		if !kept[relationUserID] {
+			removed = append(removed, relationUserID)
+		}
+	}
+	return s.DeleteRelations(ctx, uid, removed)
+}
+
+func (s *storeMock) DeletePendingContacts(ctx context.Context, uid int, numbers []string) error {
+	for _, number := range numbers {
+		delete(s.pending[uid], number)
+	}
+	return nil
+}
+
+func (s *storeMock) DeletePendingContactsExcept(ctx context.Context, uid int, keep []string) error {
+	kept := make(map[string]bool, len(keep))
+	for _, number := range keep {
+		kept[number] = true
+	}
+	for number := range s.pending[uid] {
+		if !kept[number] {
+			delete(s.pending[uid], number)
+		}
+	}
+	return nil
+}
+
+func (s *storeMock) NextSyncVersion(ctx context.Context, uid int) (int64, error) {
+	s.versions[uid]++
+	return s.versions[uid], nil
+}
+
+func (s *storeMock) AdvanceSyncVersion(ctx context.Context, uid int, expected int64) (int64, bool, error) {
+	if s.versions[uid] != expected {
+		return 0, false, nil
+	}
+	s.versions[uid]++
+	return s.versions[uid], true, nil
+}
+
+func (s *storeMock) SaveAuditEvent(ctx context.Context, event *entities.AuditEvent) error {
+	s.events = append(s.events, event)
+	return nil
+}
+
+func (s *storeMock) relationUserIDs(uid int) []int {
+	ids := make([]int, 0, len(s.relations[uid]))
+	for relationUserID := range s.relations[uid] {
+		ids = append(ids, relationUserID)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func newTestService(t *testing.T, store ContactStore) *ContactService {
+	t.Helper()
+
+	normalizer, err := phone.NewNormalizer(phone.DefaultRegion)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewContactService(store, normalizer, nil, nil, nil, logrus.New())
+}
+
+func TestContactService_SyncContacts(t *testing.T) {
+	store := newStoreMock(map[string]int{
+		"+79830000002": 2,
+		"+79830000003": 3,
+		"+79830000004": 4,
+	})
+	store.relations[1] = map[int]string{2: "Мама", 3: "Бывший коллега"}
+	store.pending[1] = map[string]string{"+79830000009": "Удаленный"}
+	svc := newTestService(t, store)
+
+	result, err := svc.SyncContacts(context.Background(), 1, entities.ContactList{
+		{Name: "Мама", PhoneNumber: "+79830000002"},
+		{Name: "Друг", PhoneNumber: "8 983 000-00-04"},
+		{Name: "Новый", PhoneNumber: "+79830000005"},
+		{Name: "Ошибка", PhoneNumber: "abc"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Created != 1 || result.Existing != 1 || result.Removed != 1 {
+		t.Fatalf("ожидалось created=1 existing=1 removed=1, получено %+v", result)
+	}
+	if len(result.Rejected) != 1 || result.Rejected[0].Index != 3 {
+		t.Fatalf("ожидался один отклоненный контакт с индексом 3, получено %+v", result.Rejected)
+	}
+	if result.SyncToken != "1" {
+		t.Fatalf("ожидался токен 1, получен %q", result.SyncToken)
+	}
+	if len(store.events) != 1 || store.events[0].Action != entities.AuditSyncContacts || store.events[0].Count != 4 {
+		t.Fatalf("ожидалось событие аудита полной синхронизации, получено %+v", store.events)
+	}
+
+	if ids := store.relationUserIDs(1); len(ids) != 2 || ids[0] != 2 || ids[1] != 4 {
+		t.Fatalf("контакта, которого нет в адресной книге, не должно остаться, связи %v", ids)
+	}
+	if _, ok := store.pending[1]["+79830000009"]; ok || len(store.pending[1]) != 1 {
+		t.Fatalf("отложенные контакты должны совпадать с адресной книгой, получено %v", store.pending[1])
+	}
+}
+
+func TestContactService_SyncContactsDelta(t *testing.T) {
+	store := newStoreMock(map[string]int{
+		"+79830000002": 2,
+		"+79830000003": 3,
+	})
+	store.relations[1] = map[int]string{2: "Мама"}
+	store.pending[1] = map[string]string{"+79830000009": "Новый"}
+	store.versions[1] = 3
+	svc := newTestService(t, store)
+
+	delta := entities.ContactsDelta{
+		SyncToken: "3",
+		Added:     entities.ContactList{{Name: "Папа", PhoneNumber: "+79830000003"}},
+		Removed: entities.ContactList{
+			{PhoneNumber: "+79830000002"},
+			{Name: strings.Repeat("я", 1000), PhoneNumber: "+79830000009"},
+			{PhoneNumber: "abc"},
+		},
+	}
+	result, err := svc.SyncContactsDelta(context.Background(), 1, delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Created != 1 || result.Removed != 1 || result.SyncToken != "4" {
+		t.Fatalf("ожидалось created=1 removed=1 token=4, получено %+v", result)
+	}
+	if ids := store.relationUserIDs(1); len(ids) != 1 || ids[0] != 3 {
+		t.Fatalf("ожидалась только связь с пользователем 3, связи %v", ids)
+	}
+	// имя удаляемого контакта не проверяется, некорректный номер возвращается в отклоненных
+	if len(result.Rejected) != 1 || result.Rejected[0].Index != 2 || result.Rejected[0].List != "removed" {
+		t.Fatalf("ожидался отклоненный удаляемый контакт с индексом 2, получено %+v", result.Rejected)
+	}
+	if len(store.pending[1]) != 0 {
+		t.Fatalf("удаленный отложенный контакт должен исчезнуть, получено %v", store.pending[1])
+	}
+	if len(store.events) != 1 || store.events[0].Action != entities.AuditSyncContactsDelta || store.events[0].Count != 4 {
+		t.Fatalf("ожидалось событие аудита синхронизации изменений, получено %+v", store.events)
+	}
+
+	// повтор с тем же токеном: версия уже ушла вперед
+	if _, err := svc.SyncContactsDelta(context.Background(), 1, delta); !errors.Is(err, ErrSyncTokenMismatch) {
+		t.Fatalf("ожидалась ErrSyncTokenMismatch, получено %v", err)
+	}
+	if ids := store.relationUserIDs(1); len(ids) != 1 || ids[0] != 3 {
+		t.Fatalf("при несовпадении токена изменений быть не должно, связи %v", ids)
+	}
+
+	delta.SyncToken = "не число"
+	if _, err := svc.SyncContactsDelta(context.Background(), 1, delta); !errors.Is(err, ErrSyncTokenMismatch) {
+		t.Fatalf("ожидалась ErrSyncTokenMismatch для некорректного токена, получено %v", err)
+	}
+}
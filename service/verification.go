@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"integ/entities"
+	"integ/storage"
+	"math/big"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrVerificationTooSoon  = errors.New("verification code was requested too recently")
+	ErrVerificationExpired  = errors.New("verification code expired")
+	ErrVerificationAttempts = errors.New("too many verification attempts")
+	ErrVerificationCode     = errors.New("invalid verification code")
+)
+
+type SMSSender interface {
+	Send(ctx context.Context, number, text string) error
+}
+
+// VerificationPolicy параметры одноразовых кодов подтверждения номера
+type VerificationPolicy struct {
+	CodeLength     int
+	CodeTTL        time.Duration
+	MaxAttempts    int
+	ResendInterval time.Duration
+}
+
+var DefaultVerificationPolicy = VerificationPolicy{
+	CodeLength:     6,
+	CodeTTL:        10 * time.Minute,
+	MaxAttempts:    5,
+	ResendInterval: time.Minute,
+}
+
+// WithVerificationPolicy задает параметры кодов подтверждения, нулевые поля берутся из DefaultVerificationPolicy
+func WithVerificationPolicy(policy VerificationPolicy) func(*ContactService) {
+	return func(c *ContactService) {
+		if policy.CodeLength > 0 {
+			c.verification.CodeLength = policy.CodeLength
+		}
+		if policy.CodeTTL > 0 {
+			c.verification.CodeTTL = policy.CodeTTL
+		}
+		if policy.MaxAttempts > 0 {
+			c.verification.MaxAttempts = policy.MaxAttempts
+		}
+		if policy.ResendInterval > 0 {
+			c.verification.ResendInterval = policy.ResendInterval
+		}
+	}
+}
+
+// RequestPhoneVerification отправляет на номер пользователя новый код подтверждения
+func (c *ContactService) RequestPhoneVerification(ctx context.Context, userID int) (*entities.PhoneVerificationStarted, error) {
+	var started *entities.PhoneVerificationStarted
+	err := c.store.WithinTx(ctx, pgx.TxOptions{}, func(ctx context.Context) error {
+		user, err := c.store.GetUser(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		previous, err := c.store.GetPhoneVerification(ctx, userID)
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return err
+		}
+		if err == nil {
+			if time.Since(previous.CreatedAt) < c.verification.ResendInterval {
+				return ErrVerificationTooSoon
+			}
+			// попытки не сбрасываются повторной отправкой, пока действует предыдущий код
+			if previous.Attempts >= c.verification.MaxAttempts && time.Now().Before(previous.ExpiresAt) {
+				return ErrVerificationAttempts
+			}
+		}
+
+		code, err := generateCode(c.verification.CodeLength)
+		if err != nil {
+			return err
+		}
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return fmt.Errorf("failed to generate salt: %w", err)
+		}
+
+		verification := &entities.PhoneVerification{
+			UserID:      userID,
+			PhoneNumber: user.PhoneNumber,
+			CodeHash:    hashCode(salt, user.PhoneNumber, code),
+			Salt:        salt,
+			ExpiresAt:   time.Now().Add(c.verification.CodeTTL),
+		}
+		if err := c.store.SavePhoneVerification(ctx, verification); err != nil {
+			return err
+		}
+
+		// отправка внутри транзакции: если сообщение не ушло, код не сохранится
+		if err := c.sms.Send(ctx, user.PhoneNumber, fmt.Sprintf("Код подтверждения: %s", code)); err != nil {
+			return fmt.Errorf("failed to send verification code: %w", err)
+		}
+
+		started = &entities.PhoneVerificationStarted{ExpiresAt: verification.ExpiresAt}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return started, nil
+}
+
+// ConfirmPhoneVerification проверяет код и отмечает номер подтвержденным,
+// после подтверждения номер участвует в сопоставлении контактов
+func (c *ContactService) ConfirmPhoneVerification(ctx context.Context, userID int, code string) (*entities.User, error) {
+	var (
+		user    *entities.User
+		joined  entities.RelationList
+		codeErr error
+	)
+	err := c.store.WithinTx(ctx, pgx.TxOptions{}, func(ctx context.Context) error {
+		verification, err := c.store.GetPhoneVerification(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		// ошибки кода возвращаются после фиксации транзакции,
+		// чтобы сохранить счетчик попыток и удаление просроченного кода
+		switch {
+		case time.Now().After(verification.ExpiresAt):
+			codeErr = ErrVerificationExpired
+			return c.store.DeletePhoneVerification(ctx, userID)
+		case verification.Attempts >= c.verification.MaxAttempts:
+			codeErr = ErrVerificationAttempts
+			return nil
+		case !hmac.Equal(hashCode(verification.Salt, verification.PhoneNumber, code), verification.CodeHash):
+			codeErr = ErrVerificationCode
+			return c.store.IncrementVerificationAttempts(ctx, userID)
+		}
+
+		user, err = c.store.MarkPhoneVerified(ctx, userID, verification.PhoneNumber)
+		if err != nil {
+			return err
+		}
+
+		if err := c.store.DeletePhoneVerification(ctx, userID); err != nil {
+			return err
+		}
+
+		joined, err = c.store.MaterializePendingContacts(ctx, user)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if codeErr != nil {
+		return nil, codeErr
+	}
+
+	c.publishContactsJoined(ctx, joined)
+
+	return user, nil
+}
+
+// generateCode случайный код из length цифр
+func generateCode(length int) (string, error) {
+	max := big.NewInt(10)
+	code := make([]byte, length)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate verification code: %w", err)
+		}
+		code[i] = byte('0' + n.Int64())
+	}
+
+	return string(code), nil
+}
+
+func hashCode(salt []byte, number, code string) []byte {
+	h := sha256.New()
+	h.Write(salt)
+	h.Write([]byte(number))
+	h.Write([]byte{0})
+	h.Write([]byte(code))
+	return h.Sum(nil)
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"integ/entities"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type senderMock struct {
+	sent int
+}
+
+func (s *senderMock) Send(ctx context.Context, number, text string) error {
+	s.sent++
+	return nil
+}
+
+// verificationStoreMock хранит один код подтверждения пользователя
+type verificationStoreMock struct {
+	*storeMock
+
+	verification *entities.PhoneVerification
+}
+
+func (s *verificationStoreMock) GetUser(ctx context.Context, uid int) (*entities.User, error) {
+	return &entities.User{UserID: uid, PhoneNumber: "+79830000001"}, nil
+}
+
+func (s *verificationStoreMock) GetPhoneVerification(ctx context.Context, uid int) (*entities.PhoneVerification, error) {
+	return s.verification, nil
+}
+
+func (s *verificationStoreMock) SavePhoneVerification(ctx context.Context, verification *entities.PhoneVerification) error {
+	s.verification = verification
+	return nil
+}
+
+func TestContactService_RequestPhoneVerificationAttempts(t *testing.T) {
+	store := &verificationStoreMock{
+		storeMock: newStoreMock(nil),
+		verification: &entities.PhoneVerification{
+			UserID:    1,
+			Attempts:  DefaultVerificationPolicy.MaxAttempts,
+			ExpiresAt: time.Now().Add(time.Minute),
+			CreatedAt: time.Now().Add(-2 * DefaultVerificationPolicy.ResendInterval),
+		},
+	}
+	sender := &senderMock{}
+	svc := NewContactService(store, nil, nil, nil, sender, logrus.New())
+
+	if _, err := svc.RequestPhoneVerification(context.Background(), 1); !errors.Is(err, ErrVerificationAttempts) {
+		t.Fatalf("повторная отправка не должна сбрасывать исчерпанные попытки, получено %v", err)
+	}
+	if sender.sent != 0 {
+		t.Fatal("код не должен отправляться, пока попытки исчерпаны")
+	}
+
+	// после истечения кода можно запросить новый
+	store.verification.ExpiresAt = time.Now().Add(-time.Second)
+	if _, err := svc.RequestPhoneVerification(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	if sender.sent != 1 {
+		t.Fatalf("ожидалась одна отправка кода, отправлено %d", sender.sent)
+	}
+}
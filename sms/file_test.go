@@ -0,0 +1,34 @@
+package sms
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileSender_Send(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sms.log")
+	sender := NewFileSender(path)
+
+	if err := sender.Send(context.Background(), "+79831234567", "Код 123456"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sender.Send(context.Background(), "+79831234568", "Код 654321"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("ожидалось 2 сообщения, получено %d", len(lines))
+	}
+	if !strings.HasSuffix(lines[1], "\t+79831234568\tКод 654321") {
+		t.Fatalf("неверная запись сообщения: %q", lines[1])
+	}
+}
@@ -0,0 +1,35 @@
+package sms
+
+import (
+	"context"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// LogSender пишет в лог факт отправки вместо отправки, для локального запуска.
+// Текст сообщения с кодом и номер целиком в лог не пишутся
+type LogSender struct {
+	log logrus.FieldLogger
+}
+
+func NewLogSender(log logrus.FieldLogger) *LogSender {
+	return &LogSender{
+		log: log,
+	}
+}
+
+func (s *LogSender) Send(ctx context.Context, number, text string) error {
+	s.log.WithField("to", maskNumber(number)).Info("SMS sent")
+
+	return nil
+}
+
+// maskNumber оставляет от номера только две последние цифры
+func maskNumber(number string) string {
+	const visible = 2
+	if len(number) <= visible {
+		return strings.Repeat("*", len(number))
+	}
+	return strings.Repeat("*", len(number)-visible) + number[len(number)-visible:]
+}
@@ -0,0 +1,33 @@
+package sms
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestLogSender_Send(t *testing.T) {
+	log, hook := test.NewNullLogger()
+	sender := NewLogSender(log)
+
+	if err := sender.Send(context.Background(), "+79831234567", "Код подтверждения: 123456"); err != nil {
+		t.Fatal(err)
+	}
+
+	entry := hook.LastEntry()
+	if entry == nil {
+		t.Fatal("отправка должна попасть в лог")
+	}
+	line, err := entry.String()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(line, "123456") || strings.Contains(line, "+79831234567") {
+		t.Fatalf("код и номер не должны попадать в лог: %q", line)
+	}
+	if entry.Data["to"] != "**********67" {
+		t.Fatalf("ожидался замаскированный номер, получено %v", entry.Data["to"])
+	}
+}
@@ -0,0 +1,194 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"integ/entities"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+)
+
+var auditCols = []interface{}{
+	"audit_event_id",
+	"action",
+	"actor_user_id",
+	"actor_subject",
+	"actor_role",
+	"target_user_id",
+	"item_count",
+	"request_id",
+	"created_at",
+}
+
+// AuditQuery параметры выборки журнала аудита, нулевые поля не ограничивают выборку.
+// From включительно, To не включительно, Cursor - audit_event_id последней строки предыдущей страницы,
+// события отдаются от новых к старым
+type AuditQuery struct {
+	From         time.Time
+	To           time.Time
+	Action       entities.AuditAction
+	ActorUserID  int
+	ActorSubject string
+	TargetUserID int
+	Cursor       int64
+	Limit        uint
+}
+
+func (s *Store) SaveAuditEvent(ctx context.Context, event *entities.AuditEvent) error {
+	insertSQL, args, err := dialect.
+		Insert("audit_events").
+		Prepared(true).
+		Rows(goqu.Record{
+			"action":         string(event.Action),
+			"actor_user_id":  event.ActorUserID,
+			"actor_subject":  event.ActorSubject,
+			"actor_role":     event.ActorRole,
+			"target_user_id": event.TargetUserID,
+			"item_count":     event.Count,
+			"request_id":     event.RequestID,
+		}).
+		ToSQL()
+	if err != nil {
+		return fmt.Errorf("failed to build a query save audit event: %w", err)
+	}
+
+	if _, err := s.Exec(ctx, insertSQL, args...); err != nil {
+		return dbError("failed to execute a query save audit event", err)
+	}
+
+	return nil
+}
+
+func (s *Store) FindAuditEvents(ctx context.Context, query AuditQuery) ([]*entities.AuditEvent, error) {
+	ds := dialect.
+		From("audit_events").
+		Prepared(true).
+		Select(auditCols...).
+		Order(goqu.C("audit_event_id").Desc())
+
+	if !query.From.IsZero() {
+		ds = ds.Where(goqu.C("created_at").Gte(query.From))
+	}
+	if !query.To.IsZero() {
+		ds = ds.Where(goqu.C("created_at").Lt(query.To))
+	}
+	if query.Action != "" {
+		ds = ds.Where(goqu.C("action").Eq(string(query.Action)))
+	}
+	if query.ActorUserID > 0 {
+		ds = ds.Where(goqu.C("actor_user_id").Eq(query.ActorUserID))
+	}
+	if query.ActorSubject != "" {
+		ds = ds.Where(goqu.C("actor_subject").Eq(query.ActorSubject))
+	}
+	if query.TargetUserID > 0 {
+		ds = ds.Where(goqu.C("target_user_id").Eq(query.TargetUserID))
+	}
+	if query.Cursor > 0 {
+		ds = ds.Where(goqu.C("audit_event_id").Lt(query.Cursor))
+	}
+	if query.Limit > 0 {
+		ds = ds.Limit(query.Limit)
+	}
+
+	selectSQL, args, err := ds.ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build a query audit events: %w", err)
+	}
+
+	return s.queryAuditEvents(ctx, selectSQL, args...)
+}
+
+// auditActorUser метка инициатора без роли, то есть другого пользователя, в выгрузке данных
+const auditActorUser = "user"
+
+// FindUserAuditEvents события, в которых пользователь был инициатором или чьи данные затрагивались,
+// для выгрузки данных пользователя: другие инициаторы (администраторы, пользователи) обезличиваются
+// до метки роли, идентификатор запроса отдается только в собственных событиях
+func (s *Store) FindUserAuditEvents(ctx context.Context, uid int) ([]*entities.AuditEvent, error) {
+	selectSQL, args, err := dialect.
+		From("audit_events").
+		Prepared(true).
+		Select(auditCols...).
+		Where(goqu.Or(
+			goqu.C("actor_user_id").Eq(uid),
+			goqu.C("target_user_id").Eq(uid),
+		)).
+		Order(goqu.C("audit_event_id").Asc()).
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build a query user audit events: %w", err)
+	}
+
+	events, err := s.queryAuditEvents(ctx, selectSQL, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, event := range events {
+		if event.ActorUserID != nil && *event.ActorUserID == uid {
+			continue
+		}
+		event.ActorUserID = nil
+		event.ActorSubject = ""
+		event.RequestID = ""
+		if event.ActorRole == "" {
+			event.ActorRole = auditActorUser
+		}
+	}
+
+	return events, nil
+}
+
+// PruneAuditEvents удаляет события старше before, возвращает количество удаленных
+func (s *Store) PruneAuditEvents(ctx context.Context, before time.Time) (int, error) {
+	deleteSQL, args, err := dialect.
+		Delete("audit_events").
+		Prepared(true).
+		Where(goqu.C("created_at").Lt(before)).
+		ToSQL()
+	if err != nil {
+		return 0, fmt.Errorf("failed to build a query prune audit events: %w", err)
+	}
+
+	affected, err := s.Exec(ctx, deleteSQL, args...)
+	if err != nil {
+		return 0, dbError("failed to execute a query prune audit events", err)
+	}
+
+	return int(affected), nil
+}
+
+func (s *Store) queryAuditEvents(ctx context.Context, sql string, args ...interface{}) ([]*entities.AuditEvent, error) {
+	rows, err := s.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, dbError("failed to execute a query audit events", err)
+	}
+	defer rows.Close()
+
+	events := make([]*entities.AuditEvent, 0)
+	for rows.Next() {
+		var event entities.AuditEvent
+		err := rows.Scan(
+			&event.EventID,
+			&event.Action,
+			&event.ActorUserID,
+			&event.ActorSubject,
+			&event.ActorRole,
+			&event.TargetUserID,
+			&event.Count,
+			&event.RequestID,
+			&event.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read audit event from database: %w", err)
+		}
+		events = append(events, &event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError("failed to read audit events", err)
+	}
+
+	return events, nil
+}
@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	// ErrNotFound запрошенная запись не существует
+	ErrNotFound = errors.New("not found")
+	// ErrConflict запись конфликтует с уже существующими данными
+	ErrConflict = errors.New("conflict")
+	// ErrInvalid данные или параметры запроса не прошли проверку
+	ErrInvalid = errors.New("invalid")
+	// ErrInvalidCursor курсор страницы не указывает на строку выборки, например она уже удалена
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// коды ошибок postgres, https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	pgStringDataRightTruncation = "22001"
+	pgInvalidTextRepresentation = "22P02"
+	pgNotNullViolation          = "23502"
+	pgForeignKeyViolation       = "23503"
+	pgUniqueViolation           = "23505"
+	pgCheckViolation            = "23514"
+)
+
+// dbError оборачивает ошибку выполнения запроса, нарушения ограничений
+// переводятся в ErrConflict и ErrInvalid
+func dbError(msg string, err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return fmt.Errorf("%s: %w", msg, err)
+	}
+
+	switch pgErr.Code {
+	case pgUniqueViolation:
+		return fmt.Errorf("%s: %w: %s", msg, ErrConflict, pgErr.Message)
+	case pgStringDataRightTruncation, pgInvalidTextRepresentation,
+		pgNotNullViolation, pgForeignKeyViolation, pgCheckViolation:
+		return fmt.Errorf("%s: %w: %s", msg, ErrInvalid, pgErr.Message)
+	}
+
+	return fmt.Errorf("%s: %w", msg, err)
+}
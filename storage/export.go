@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"integ/entities"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+)
+
+// ExportUser собирает все данные пользователя, для согласованной выгрузки
+// должен выполняться в транзакции с уровнем изоляции не ниже REPEATABLE READ
+func (s *Store) ExportUser(ctx context.Context, uid int) (*entities.UserExport, error) {
+	user, err := s.exportedUser(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &entities.UserExport{
+		ExportedAt:        time.Now().UTC(),
+		User:              *user,
+		Relations:         make([]entities.ExportedRelation, 0),
+		IncomingRelations: make([]entities.ExportedIncomingRelation, 0),
+		PendingContacts:   make([]entities.ExportedPendingContact, 0),
+		Blocks:            make([]entities.ExportedBlock, 0),
+	}
+
+	relations := dialect.From("relations").
+		Select("relation_user_id", "contact_name", "added_at").
+		Where(goqu.C("user_id").Eq(uid)).
+		Order(goqu.C("relation_id").Asc())
+	err = s.exportRows(ctx, "relations", relations, func(rows Rows) error {
+		var relation entities.ExportedRelation
+		if err := rows.Scan(&relation.RelationUserID, &relation.ContactName, &relation.AddedAt); err != nil {
+			return err
+		}
+		export.Relations = append(export.Relations, relation)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	incoming := dialect.From("relations").
+		Select("user_id", "added_at").
+		Where(goqu.C("relation_user_id").Eq(uid)).
+		Order(goqu.C("relation_id").Asc())
+	err = s.exportRows(ctx, "incoming relations", incoming, func(rows Rows) error {
+		var relation entities.ExportedIncomingRelation
+		if err := rows.Scan(&relation.UserID, &relation.AddedAt); err != nil {
+			return err
+		}
+		export.IncomingRelations = append(export.IncomingRelations, relation)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pending := dialect.From("pending_contacts").
+		Select("phone_number", "contact_name", "created_at").
+		Where(goqu.C("user_id").Eq(uid)).
+		Order(goqu.C("pending_contact_id").Asc())
+	err = s.exportRows(ctx, "pending contacts", pending, func(rows Rows) error {
+		var contact entities.ExportedPendingContact
+		if err := rows.Scan(&contact.PhoneNumber, &contact.ContactName, &contact.CreatedAt); err != nil {
+			return err
+		}
+		number, err := s.openPhone(contact.PhoneNumber)
+		if err != nil {
+			return err
+		}
+		contact.PhoneNumber = number
+		export.PendingContacts = append(export.PendingContacts, contact)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	blocks := dialect.From("blocks").
+		Select("blocked_user_id", "created_at").
+		Where(goqu.C("user_id").Eq(uid)).
+		Order(goqu.C("created_at").Asc(), goqu.C("blocked_user_id").Asc())
+	err = s.exportRows(ctx, "blocks", blocks, func(rows Rows) error {
+		var block entities.ExportedBlock
+		if err := rows.Scan(&block.BlockedUserID, &block.CreatedAt); err != nil {
+			return err
+		}
+		export.Blocks = append(export.Blocks, block)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	export.AuditEvents, err = s.FindUserAuditEvents(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
+
+func (s *Store) exportedUser(ctx context.Context, uid int) (*entities.ExportedUser, error) {
+	selectSQL, args, err := userTable.
+		Select("user_id", "name", "phone_number", "phone_verified_at", "discoverability").
+		Where(
+			goqu.C("user_id").Eq(uid),
+			goqu.C("erased_at").IsNull(),
+		).
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build a query export user: %w", err)
+	}
+
+	rows, err := s.Query(ctx, selectSQL, args...)
+	if err != nil {
+		return nil, dbError("failed to execute a query export user", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, dbError("failed to read user", err)
+		}
+		return nil, fmt.Errorf("user %d: %w", uid, ErrNotFound)
+	}
+
+	var user entities.ExportedUser
+	err = rows.Scan(&user.UserID, &user.Name, &user.PhoneNumber, &user.PhoneVerifiedAt, &user.Discoverability)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read user from database: %w", err)
+	}
+
+	user.PhoneNumber, err = s.openPhone(user.PhoneNumber)
+	if err != nil {
+		return nil, fmt.Errorf("user %d: %w", uid, err)
+	}
+
+	return &user, nil
+}
+
+// exportRows выполняет запрос раздела выгрузки what и передает каждую строку в scan
+func (s *Store) exportRows(ctx context.Context, what string, query *goqu.SelectDataset, scan func(rows Rows) error) error {
+	selectSQL, args, err := query.Prepared(true).ToSQL()
+	if err != nil {
+		return fmt.Errorf("failed to build a query export %s: %w", what, err)
+	}
+
+	rows, err := s.Query(ctx, selectSQL, args...)
+	if err != nil {
+		return dbError("failed to execute a query export "+what, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return fmt.Errorf("failed to read %s from database: %w", what, err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return dbError("failed to read "+what, err)
+	}
+
+	return nil
+}
@@ -1,22 +1,88 @@
 package filtering
 
 import (
+	"fmt"
+	"reflect"
+
 	"github.com/doug-martin/goqu/v9"
 	"github.com/doug-martin/goqu/v9/exp"
 )
 
+// Операторы сравнения фильтра
+const (
+	OpEq      = "="
+	OpNeq     = "!="
+	OpGt      = ">"
+	OpGte     = ">="
+	OpLt      = "<"
+	OpLte     = "<="
+	OpIn      = "IN"
+	OpNotIn   = "NOT IN"
+	OpLike    = "LIKE"
+	OpILike   = "ILIKE"
+	OpIsNull  = "IS NULL"
+	OpBetween = "BETWEEN"
+)
+
+// Expression условие выборки: одиночный фильтр или группа фильтров
+type Expression interface {
+	GetExp() (exp.Expression, error)
+}
+
+// Filter сравнение поля со значением.
+// Для IN/NOT IN значение - слайс, для BETWEEN - слайс из двух границ,
+// для IS NULL - bool (false означает IS NOT NULL)
 type Filter struct {
 	Field string
 	Op    string
 	Value interface{}
 }
 
-func (f *Filter) GetExp() exp.Expression {
+func (f *Filter) GetExp() (exp.Expression, error) {
+	col := goqu.I(f.Field)
+
 	switch f.Op {
-	case ">":
-		return goqu.C(f.Field).Gt(f.Value)
-	case "=":
-		return goqu.C(f.Field).Eq(f.Value)
+	case OpEq:
+		return col.Eq(f.Value), nil
+	case OpNeq:
+		return col.Neq(f.Value), nil
+	case OpGt:
+		return col.Gt(f.Value), nil
+	case OpGte:
+		return col.Gte(f.Value), nil
+	case OpLt:
+		return col.Lt(f.Value), nil
+	case OpLte:
+		return col.Lte(f.Value), nil
+	case OpIn, OpNotIn:
+		v := reflect.ValueOf(f.Value)
+		if v.Kind() != reflect.Slice || v.Len() == 0 {
+			return nil, fmt.Errorf("filter %s %s: value must be a non-empty slice", f.Field, f.Op)
+		}
+		if f.Op == OpIn {
+			return col.In(f.Value), nil
+		}
+		return col.NotIn(f.Value), nil
+	case OpLike:
+		return col.Like(f.Value), nil
+	case OpILike:
+		return col.ILike(f.Value), nil
+	case OpIsNull:
+		isNull, ok := f.Value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("filter %s %s: value must be bool", f.Field, f.Op)
+		}
+		if isNull {
+			return col.IsNull(), nil
+		}
+		return col.IsNotNull(), nil
+	case OpBetween:
+		v := reflect.ValueOf(f.Value)
+		if v.Kind() != reflect.Slice || v.Len() != 2 {
+			return nil, fmt.Errorf("filter %s %s: value must be a slice of two bounds", f.Field, f.Op)
+		}
+		return col.Between(exp.NewRangeVal(v.Index(0).Interface(), v.Index(1).Interface())), nil
 	}
-	return exp.Default()
+
+	return nil, fmt.Errorf("filter %s: unsupported operator %q", f.Field, f.Op)
 }
@@ -0,0 +1,135 @@
+package filtering
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/doug-martin/goqu/v9"
+	_ "github.com/doug-martin/goqu/v9/dialect/postgres"
+)
+
+func toSQL(t *testing.T, e Expression) (string, []interface{}) {
+	t.Helper()
+
+	where, err := e.GetExp()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sql, args, err := goqu.Dialect("postgres").From("users").Prepared(true).Where(where).ToSQL()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return sql, args
+}
+
+func TestFilter_GetExp(t *testing.T) {
+	cases := []struct {
+		filter Filter
+		want   string
+	}{
+		{Filter{"name", OpEq, "a"}, `SELECT * FROM "users" WHERE ("name" = $1)`},
+		{Filter{"name", OpNeq, "a"}, `SELECT * FROM "users" WHERE ("name" != $1)`},
+		{Filter{"user_id", OpGte, 1}, `SELECT * FROM "users" WHERE ("user_id" >= $1)`},
+		{Filter{"user_id", OpLt, 1}, `SELECT * FROM "users" WHERE ("user_id" < $1)`},
+		{Filter{"user_id", OpIn, []int{1, 2}}, `SELECT * FROM "users" WHERE ("user_id" IN ($1, $2))`},
+		{Filter{"user_id", OpNotIn, []int{1}}, `SELECT * FROM "users" WHERE ("user_id" NOT IN ($1))`},
+		{Filter{"users.name", OpILike, "a%"}, `SELECT * FROM "users" WHERE ("users"."name" ILIKE $1)`},
+		{Filter{"name", OpIsNull, true}, `SELECT * FROM "users" WHERE ("name" IS NULL)`},
+		{Filter{"name", OpIsNull, false}, `SELECT * FROM "users" WHERE ("name" IS NOT NULL)`},
+		{Filter{"user_id", OpBetween, []int{1, 5}}, `SELECT * FROM "users" WHERE ("user_id" BETWEEN $1 AND $2)`},
+	}
+
+	for _, c := range cases {
+		sql, _ := toSQL(t, &c.filter)
+		if sql != c.want {
+			t.Fatalf("%s %s: ожидалось %s, получено %s", c.filter.Field, c.filter.Op, c.want, sql)
+		}
+	}
+}
+
+func TestFilter_GetExpUnsupported(t *testing.T) {
+	for _, f := range []Filter{
+		{"name", "~", "a"},
+		{"user_id", OpIn, []int{}},
+		{"user_id", OpBetween, []int{1}},
+		{"name", OpIsNull, "yes"},
+	} {
+		if _, err := f.GetExp(); err == nil {
+			t.Fatalf("%s %s: ожидалась ошибка", f.Field, f.Op)
+		}
+	}
+}
+
+func TestGroup_GetExp(t *testing.T) {
+	e := And(
+		&Filter{"name", OpEq, "a"},
+		Or(
+			&Filter{"user_id", OpGt, 1},
+			Not(&Filter{"phone_number", OpLike, "+7%"}),
+		),
+	)
+
+	sql, args := toSQL(t, e)
+	want := `SELECT * FROM "users" WHERE (("name" = $1) AND (("user_id" > $2) OR NOT ("phone_number" LIKE $3)))`
+	if sql != want {
+		t.Fatalf("ожидалось %s, получено %s", want, sql)
+	}
+	if !reflect.DeepEqual(args, []interface{}{"a", int64(1), "+7%"}) {
+		t.Fatalf("неверные аргументы %v", args)
+	}
+}
+
+func TestWhitelist_ParseQuery(t *testing.T) {
+	w := Whitelist{
+		"name":    {Name: "users.name", Type: TypeString},
+		"user_id": {Name: "users.user_id", Type: TypeInt},
+	}
+
+	values := url.Values{
+		"name__ilike":      {"ann*"},
+		"user_id__between": {"1,10"},
+		"limit":            {"20"},
+	}
+
+	e, err := w.ParseQuery(values, "limit")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sql, args := toSQL(t, e)
+	want := `SELECT * FROM "users" WHERE (("users"."name" ILIKE $1) AND ("users"."user_id" BETWEEN $2 AND $3))`
+	if sql != want {
+		t.Fatalf("ожидалось %s, получено %s", want, sql)
+	}
+	if !reflect.DeepEqual(args, []interface{}{"ann%", int64(1), int64(10)}) {
+		t.Fatalf("неверные аргументы %v", args)
+	}
+}
+
+func TestWhitelist_ParseQueryRejected(t *testing.T) {
+	w := Whitelist{
+		"name":    {Name: "users.name", Type: TypeString},
+		"user_id": {Name: "users.user_id", Type: TypeInt},
+	}
+
+	for _, values := range []url.Values{
+		{"password": {"x"}},
+		{"name__regex": {"x"}},
+		{"user_id": {"abc"}},
+		{"user_id__like": {"1*"}},
+		{"user_id__between": {"1"}},
+	} {
+		if _, err := w.ParseQuery(values); err == nil {
+			t.Fatalf("%v: ожидалась ошибка", values)
+		}
+	}
+}
+
+func TestLikePattern(t *testing.T) {
+	if got := likePattern(`a_b%c*`); got != `a\_b\%c%` {
+		t.Fatalf("неверный шаблон %s", got)
+	}
+}
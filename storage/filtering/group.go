@@ -0,0 +1,57 @@
+package filtering
+
+import (
+	"fmt"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+)
+
+const (
+	groupAnd = "AND"
+	groupOr  = "OR"
+	groupNot = "NOT"
+)
+
+// Group логическая композиция условий
+type Group struct {
+	op    string
+	items []Expression
+}
+
+func And(items ...Expression) *Group {
+	return &Group{op: groupAnd, items: items}
+}
+
+func Or(items ...Expression) *Group {
+	return &Group{op: groupOr, items: items}
+}
+
+func Not(item Expression) *Group {
+	return &Group{op: groupNot, items: []Expression{item}}
+}
+
+func (g *Group) GetExp() (exp.Expression, error) {
+	exps := make([]exp.Expression, 0, len(g.items))
+	for _, item := range g.items {
+		e, err := item.GetExp()
+		if err != nil {
+			return nil, err
+		}
+		exps = append(exps, e)
+	}
+
+	switch g.op {
+	case groupAnd:
+		return goqu.And(exps...), nil
+	case groupOr:
+		return goqu.Or(exps...), nil
+	case groupNot:
+		if len(exps) != 1 {
+			return nil, fmt.Errorf("NOT group must contain exactly one expression")
+		}
+		return goqu.L("NOT ?", exps[0]), nil
+	}
+
+	return nil, fmt.Errorf("unsupported group operator %q", g.op)
+}
@@ -0,0 +1,148 @@
+package filtering
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Типы значений колонок, к ним приводятся строковые значения из запроса
+const (
+	TypeString = iota
+	TypeInt
+	TypeTime
+)
+
+// Column колонка, доступная для фильтрации
+type Column struct {
+	Name string
+	Type int
+}
+
+// Whitelist колонки таблицы, по которым разрешено фильтровать,
+// ключ - имя поля во внешнем API, чтобы в запрос не попадали произвольные идентификаторы
+type Whitelist map[string]Column
+
+const opSeparator = "__"
+
+var queryOps = map[string]string{
+	"":        OpEq,
+	"eq":      OpEq,
+	"ne":      OpNeq,
+	"gt":      OpGt,
+	"gte":     OpGte,
+	"lt":      OpLt,
+	"lte":     OpLte,
+	"in":      OpIn,
+	"nin":     OpNotIn,
+	"like":    OpLike,
+	"ilike":   OpILike,
+	"isnull":  OpIsNull,
+	"between": OpBetween,
+}
+
+// ParseQuery разбирает параметры вида field__op=value в AND-группу фильтров.
+// Без суффикса оператор - равенство, для in/nin/between значения перечисляются через запятую,
+// в like/ilike "*" заменяет любую последовательность символов.
+// Параметры из skip (пагинация, сортировка) пропускаются.
+func (w Whitelist) ParseQuery(values url.Values, skip ...string) (*Group, error) {
+	skipped := make(map[string]struct{}, len(skip))
+	for _, key := range skip {
+		skipped[key] = struct{}{}
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		if _, ok := skipped[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	filters := make([]Expression, 0, len(keys))
+	for _, key := range keys {
+		for _, raw := range values[key] {
+			filter, err := w.parseParam(key, raw)
+			if err != nil {
+				return nil, err
+			}
+			filters = append(filters, filter)
+		}
+	}
+
+	return And(filters...), nil
+}
+
+func (w Whitelist) parseParam(key, raw string) (*Filter, error) {
+	field, opName := key, ""
+	if i := strings.LastIndex(key, opSeparator); i >= 0 {
+		field, opName = key[:i], key[i+len(opSeparator):]
+	}
+
+	col, ok := w[field]
+	if !ok {
+		return nil, fmt.Errorf("filtering by %q is not allowed", field)
+	}
+
+	op, ok := queryOps[opName]
+	if !ok {
+		return nil, fmt.Errorf("unknown filter operator %q", opName)
+	}
+
+	filter := &Filter{Field: col.Name, Op: op}
+
+	switch op {
+	case OpIn, OpNotIn, OpBetween:
+		parts := strings.Split(raw, ",")
+		if op == OpBetween && len(parts) != 2 {
+			return nil, fmt.Errorf("%s: between requires two values", key)
+		}
+		vals := make([]interface{}, 0, len(parts))
+		for _, part := range parts {
+			v, err := col.parseValue(part)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			vals = append(vals, v)
+		}
+		filter.Value = vals
+	case OpLike, OpILike:
+		if col.Type != TypeString {
+			return nil, fmt.Errorf("%s: like is supported only for string fields", key)
+		}
+		filter.Value = likePattern(raw)
+	case OpIsNull:
+		isNull, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		filter.Value = isNull
+	default:
+		v, err := col.parseValue(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		filter.Value = v
+	}
+
+	return filter, nil
+}
+
+func (c Column) parseValue(raw string) (interface{}, error) {
+	switch c.Type {
+	case TypeInt:
+		return strconv.Atoi(raw)
+	case TypeTime:
+		return time.Parse(time.RFC3339, raw)
+	}
+	return raw, nil
+}
+
+// likePattern экранирует спецсимволы LIKE и заменяет "*" на "%"
+func likePattern(raw string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`, `*`, `%`)
+	return r.Replace(raw)
+}
@@ -0,0 +1,209 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"integ/entities"
+
+	"github.com/doug-martin/goqu/v9"
+)
+
+var relationFriendsCols = []interface{}{
+	"r.relation_id",
+	"r.user_id",
+	"r.relation_user_id",
+	"users.name",
+	"users.phone_number",
+	"r.contact_name",
+	"r.added_at",
+}
+
+var mutualFriendsReq = dialect.From(goqu.T("relations").As("r")).
+	InnerJoin(
+		goqu.T("relations").As("back"),
+		goqu.On(
+			goqu.I("back.user_id").Eq(goqu.I("r.relation_user_id")),
+			goqu.I("back.relation_user_id").Eq(goqu.I("r.user_id")),
+		),
+	).
+	InnerJoin(goqu.T("users"), goqu.On(goqu.I("users.user_id").Eq(goqu.I("r.relation_user_id")))).
+	Prepared(true)
+
+var commonFriendsReq = dialect.From(goqu.T("relations").As("r")).
+	InnerJoin(
+		goqu.T("relations").As("other"),
+		goqu.On(goqu.I("other.relation_user_id").Eq(goqu.I("r.relation_user_id"))),
+	).
+	InnerJoin(goqu.T("users"), goqu.On(goqu.I("users.user_id").Eq(goqu.I("r.relation_user_id")))).
+	Prepared(true)
+
+var followersReq = dialect.From(goqu.T("relations").As("r")).
+	InnerJoin(goqu.T("users"), goqu.On(goqu.I("users.user_id").Eq(goqu.I("r.user_id")))).
+	Prepared(true)
+
+var suggestionsReq = dialect.From(goqu.T("relations").As("r")).
+	InnerJoin(goqu.T("relations").As("fof"), goqu.On(goqu.I("fof.user_id").Eq(goqu.I("r.relation_user_id")))).
+	InnerJoin(goqu.T("users"), goqu.On(goqu.I("users.user_id").Eq(goqu.I("fof.relation_user_id")))).
+	Prepared(true)
+
+// FindMutualFriends друзья, у которых пользователь тоже есть в контактах,
+// здесь и далее скрываются пользователи, не видимые uid (см. visibleTo)
+func (s *Store) FindMutualFriends(ctx context.Context, uid int) ([]*entities.Friend, error) {
+	selectSQL, args, err := mutualFriendsReq.
+		Select(relationFriendsCols...).
+		Where(
+			goqu.I("r.user_id").Eq(uid),
+			visibleTo(uid),
+		).
+		Order(goqu.I("r.relation_user_id").Asc()).
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build a mutual friends request: %w", err)
+	}
+
+	return s.queryFriends(ctx, selectSQL, args...)
+}
+
+// FindCommonFriends контакты, которые есть у обоих пользователей,
+// ErrNotFound если otherUID не виден uid (настройки обнаружения или блокировка)
+func (s *Store) FindCommonFriends(ctx context.Context, uid, otherUID int) ([]*entities.Friend, error) {
+	if err := s.checkVisible(ctx, uid, otherUID); err != nil {
+		return nil, err
+	}
+
+	selectSQL, args, err := commonFriendsReq.
+		Select(relationFriendsCols...).
+		Where(
+			goqu.I("r.user_id").Eq(uid),
+			goqu.I("other.user_id").Eq(otherUID),
+			visibleTo(uid),
+		).
+		Order(goqu.I("r.relation_user_id").Asc()).
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build a common friends request: %w", err)
+	}
+
+	return s.queryFriends(ctx, selectSQL, args...)
+}
+
+// FindFollowers пользователи, у которых uid есть в контактах, без номеров
+func (s *Store) FindFollowers(ctx context.Context, uid int) ([]*entities.Follower, error) {
+	selectSQL, args, err := followersReq.
+		Select(
+			"users.user_id",
+			"users.name",
+		).
+		Where(
+			goqu.I("r.relation_user_id").Eq(uid),
+			visibleTo(uid),
+		).
+		Order(goqu.I("r.user_id").Asc()).
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build a followers request: %w", err)
+	}
+
+	rows, err := s.Query(ctx, selectSQL, args...)
+	if err != nil {
+		return nil, dbError("failed to execute a followers request", err)
+	}
+	defer rows.Close()
+
+	followers := make([]*entities.Follower, 0)
+	for rows.Next() {
+		var follower entities.Follower
+		if err := rows.Scan(&follower.UserID, &follower.Name); err != nil {
+			return nil, fmt.Errorf("failed to read followers from database: %w", err)
+		}
+		followers = append(followers, &follower)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError("failed to read followers", err)
+	}
+
+	return followers, nil
+}
+
+// FindSuggestions знакомые знакомых пользователя, которых нет у него в контактах,
+// отсортированные по числу общих контактов
+func (s *Store) FindSuggestions(ctx context.Context, uid int, limit, offset uint) ([]*entities.Suggestion, error) {
+	selectSQL, args, err := suggestionsReq.
+		LeftJoin(
+			goqu.T("relations").As("known"),
+			goqu.On(
+				goqu.I("known.user_id").Eq(uid),
+				goqu.I("known.relation_user_id").Eq(goqu.I("fof.relation_user_id")),
+			),
+		).
+		Select(
+			"users.user_id",
+			"users.name",
+			goqu.COUNT(goqu.Star()).As("mutual_count"),
+		).
+		Where(
+			goqu.I("r.user_id").Eq(uid),
+			goqu.I("fof.relation_user_id").Neq(uid),
+			goqu.I("known.relation_id").IsNull(),
+			visibleTo(uid),
+		).
+		GroupBy("users.user_id", "users.name").
+		Order(goqu.I("mutual_count").Desc(), goqu.I("users.user_id").Asc()).
+		Limit(limit).
+		Offset(offset).
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build a suggestions request: %w", err)
+	}
+
+	rows, err := s.Query(ctx, selectSQL, args...)
+	if err != nil {
+		return nil, dbError("failed to execute a suggestions request", err)
+	}
+	defer rows.Close()
+
+	suggestions := make([]*entities.Suggestion, 0)
+	for rows.Next() {
+		var suggestion entities.Suggestion
+		err := rows.Scan(
+			&suggestion.UserID,
+			&suggestion.Name,
+			&suggestion.MutualCount,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read suggestions from database: %w", err)
+		}
+		suggestions = append(suggestions, &suggestion)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError("failed to read suggestions", err)
+	}
+
+	return suggestions, nil
+}
+
+func (s *Store) queryFriends(ctx context.Context, sql string, args ...interface{}) ([]*entities.Friend, error) {
+	rows, err := s.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, dbError("failed to execute a friends request", err)
+	}
+	defer rows.Close()
+
+	friends := make([]*entities.Friend, 0)
+	for rows.Next() {
+		friendRaw, err := scanFriends(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read friends from database: %w", err)
+		}
+		friend, err := s.buildFriends(friendRaw)
+		if err != nil {
+			return nil, err
+		}
+		friends = append(friends, friend)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError("failed to read friends", err)
+	}
+
+	return friends, nil
+}
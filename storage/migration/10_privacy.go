@@ -0,0 +1,38 @@
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(upPrivacy, downPrivacy)
+}
+
+func upPrivacy(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+ALTER TABLE users ADD COLUMN discoverability TEXT NOT NULL DEFAULT 'everyone'
+    CHECK (discoverability IN ('everyone', 'contacts', 'nobody'));
+
+CREATE TABLE blocks
+(
+    user_id INTEGER NOT NULL,
+    blocked_user_id INTEGER NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, blocked_user_id)
+);
+
+CREATE INDEX blocks_blocked_user_id_idx ON blocks (blocked_user_id);
+`)
+	return err
+}
+
+func downPrivacy(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+DROP TABLE IF EXISTS blocks;
+
+ALTER TABLE users DROP COLUMN IF EXISTS discoverability;
+`)
+	return err
+}
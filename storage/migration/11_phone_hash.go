@@ -0,0 +1,59 @@
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(upPhoneHash, downPhoneHash)
+}
+
+func upPhoneHash(tx *sql.Tx) error {
+	if _, err := tx.Exec(`ALTER TABLE users ADD COLUMN phone_hash TEXT;`); err != nil {
+		return err
+	}
+
+	// без ключа хеши не заполняются, такие пользователи находятся только по номеру
+	if phoneHasher != nil {
+		rows, err := tx.Query(`SELECT user_id, phone_normalized FROM users WHERE phone_normalized IS NOT NULL;`)
+		if err != nil {
+			return err
+		}
+
+		hashes := make(map[int]string)
+		for rows.Next() {
+			var (
+				userID int
+				number string
+			)
+			if err := rows.Scan(&userID, &number); err != nil {
+				rows.Close()
+				return err
+			}
+			hashes[userID] = phoneHasher.Hash(number)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for userID, hash := range hashes {
+			if _, err := tx.Exec(`UPDATE users SET phone_hash = $1 WHERE user_id = $2;`, hash, userID); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err := tx.Exec(`CREATE INDEX users_phone_hash_idx ON users (phone_hash);`)
+	return err
+}
+
+func downPhoneHash(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+DROP INDEX IF EXISTS users_phone_hash_idx;
+ALTER TABLE users DROP COLUMN IF EXISTS phone_hash;
+`)
+	return err
+}
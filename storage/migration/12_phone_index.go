@@ -0,0 +1,37 @@
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(upPhoneIndex, downPhoneIndex)
+}
+
+// upPhoneIndex заменяет phone_normalized слепым индексом phone_index. Пока номера не зашифрованы,
+// индекс совпадает с нормализованным номером, открытые номера шифруются и индекс пересчитывается
+// при запуске сервера, смену ключа выполняет команда --encrypt-phones
+func upPhoneIndex(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+ALTER TABLE users ADD COLUMN phone_index TEXT;
+UPDATE users SET phone_index = phone_normalized;
+CREATE INDEX users_phone_index_idx ON users (phone_index);
+DROP INDEX IF EXISTS users_phone_normalized_idx;
+ALTER TABLE users DROP COLUMN phone_normalized;
+`)
+	return err
+}
+
+// downPhoneIndex восстанавливает phone_normalized только для незашифрованных номеров
+func downPhoneIndex(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+ALTER TABLE users ADD COLUMN phone_normalized TEXT;
+UPDATE users SET phone_normalized = phone_index WHERE phone_number NOT LIKE 'v1.%';
+CREATE INDEX users_phone_normalized_idx ON users (phone_normalized);
+DROP INDEX IF EXISTS users_phone_index_idx;
+ALTER TABLE users DROP COLUMN IF EXISTS phone_index;
+`)
+	return err
+}
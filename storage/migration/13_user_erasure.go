@@ -0,0 +1,25 @@
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(upUserErasure, downUserErasure)
+}
+
+func upUserErasure(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+ALTER TABLE users ADD COLUMN erased_at TIMESTAMPTZ;
+`)
+	return err
+}
+
+func downUserErasure(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+ALTER TABLE users DROP COLUMN IF EXISTS erased_at;
+`)
+	return err
+}
@@ -0,0 +1,50 @@
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(upAuditEvents, downAuditEvents)
+}
+
+// upAuditEvents журнал только дополняется: изменение строк запрещено триггером,
+// удаляются только устаревшие события
+func upAuditEvents(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+CREATE TABLE audit_events
+(
+    audit_event_id BIGSERIAL PRIMARY KEY,
+    action TEXT NOT NULL,
+    actor_user_id INTEGER,
+    target_user_id INTEGER NOT NULL,
+    item_count INTEGER NOT NULL DEFAULT 0,
+    request_id TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX audit_events_created_at_idx ON audit_events (created_at);
+CREATE INDEX audit_events_actor_user_id_idx ON audit_events (actor_user_id);
+CREATE INDEX audit_events_target_user_id_idx ON audit_events (target_user_id);
+
+CREATE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_no_update BEFORE UPDATE ON audit_events
+    FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+`)
+	return err
+}
+
+func downAuditEvents(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+DROP TABLE IF EXISTS audit_events;
+DROP FUNCTION IF EXISTS audit_events_append_only();
+`)
+	return err
+}
@@ -0,0 +1,29 @@
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(upGrandfatherVerifiedPhones, downGrandfatherVerifiedPhones)
+}
+
+// upGrandfatherVerifiedPhones пользователи, зарегистрированные до подтверждения номеров,
+// считаются подтвержденными, иначе после миграции 9 их перестают находить по номеру
+func upGrandfatherVerifiedPhones(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+UPDATE users SET phone_verified_at = now()
+WHERE phone_verified_at IS NULL
+  AND phone_index IS NOT NULL
+  AND erased_at IS NULL
+  AND user_id NOT IN (SELECT user_id FROM phone_verifications);
+`)
+	return err
+}
+
+// downGrandfatherVerifiedPhones подтвержденные миграцией номера не отличить от подтвержденных кодом
+func downGrandfatherVerifiedPhones(tx *sql.Tx) error {
+	return nil
+}
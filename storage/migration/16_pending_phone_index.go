@@ -0,0 +1,49 @@
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(upPendingPhoneIndex, downPendingPhoneIndex)
+}
+
+// upPendingPhoneIndex номера отложенных контактов и кодов подтверждения хранятся так же, как номера
+// пользователей: phone_number шифруется, отложенные контакты ищутся по слепому индексу phone_index.
+// Пока номера не зашифрованы, индекс совпадает с нормализованным номером
+func upPendingPhoneIndex(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+ALTER TABLE pending_contacts RENAME COLUMN phone_normalized TO phone_number;
+ALTER TABLE pending_contacts ADD COLUMN phone_index TEXT;
+UPDATE pending_contacts SET phone_index = phone_number;
+ALTER TABLE pending_contacts ALTER COLUMN phone_index SET NOT NULL;
+ALTER TABLE pending_contacts DROP CONSTRAINT IF EXISTS pending_contacts_user_id_phone_normalized_key;
+ALTER TABLE pending_contacts ADD CONSTRAINT pending_contacts_user_id_phone_index_key UNIQUE (user_id, phone_index);
+DROP INDEX IF EXISTS pending_contacts_phone_normalized_idx;
+CREATE INDEX pending_contacts_phone_index_idx ON pending_contacts (phone_index);
+
+ALTER TABLE phone_verifications RENAME COLUMN phone_normalized TO phone_number;
+`)
+	return err
+}
+
+// downPendingPhoneIndex зашифрованные отложенные контакты и коды удаляются:
+// контакты восстановятся при следующей синхронизации, код можно запросить заново
+func downPendingPhoneIndex(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+DELETE FROM pending_contacts WHERE phone_number LIKE 'v1.%';
+DELETE FROM phone_verifications WHERE phone_number LIKE 'v1.%';
+
+DROP INDEX IF EXISTS pending_contacts_phone_index_idx;
+ALTER TABLE pending_contacts DROP CONSTRAINT IF EXISTS pending_contacts_user_id_phone_index_key;
+ALTER TABLE pending_contacts DROP COLUMN IF EXISTS phone_index;
+ALTER TABLE pending_contacts RENAME COLUMN phone_number TO phone_normalized;
+ALTER TABLE pending_contacts ADD CONSTRAINT pending_contacts_user_id_phone_normalized_key UNIQUE (user_id, phone_normalized);
+CREATE INDEX pending_contacts_phone_normalized_idx ON pending_contacts (phone_normalized);
+
+ALTER TABLE phone_verifications RENAME COLUMN phone_number TO phone_normalized;
+`)
+	return err
+}
@@ -0,0 +1,38 @@
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(upAuditActorSubject, downAuditActorSubject)
+}
+
+// upAuditActorSubject subject и роль токена инициатора, чтобы действия администраторов,
+// у которых subject не идентификатор пользователя, тоже можно было отнести к инициатору.
+// Для существующих событий subject восстанавливается из actor_user_id, на время заполнения
+// запрет изменения журнала снимается
+func upAuditActorSubject(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+ALTER TABLE audit_events ADD COLUMN actor_subject TEXT NOT NULL DEFAULT '';
+ALTER TABLE audit_events ADD COLUMN actor_role TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE audit_events DISABLE TRIGGER audit_events_no_update;
+UPDATE audit_events SET actor_subject = actor_user_id::TEXT WHERE actor_user_id IS NOT NULL;
+ALTER TABLE audit_events ENABLE TRIGGER audit_events_no_update;
+
+CREATE INDEX audit_events_actor_subject_idx ON audit_events (actor_subject);
+`)
+	return err
+}
+
+func downAuditActorSubject(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+DROP INDEX IF EXISTS audit_events_actor_subject_idx;
+ALTER TABLE audit_events DROP COLUMN IF EXISTS actor_role;
+ALTER TABLE audit_events DROP COLUMN IF EXISTS actor_subject;
+`)
+	return err
+}
@@ -0,0 +1,61 @@
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(upPhoneHashIndex, downPhoneHashIndex)
+}
+
+// upPhoneHashIndex ключ клиентских хешей номеров есть в каждом клиенте, поэтому в phone_hash
+// вместо хеша клиента хранится HMAC от него серверным ключом слепого индекса
+func upPhoneHashIndex(tx *sql.Tx) error {
+	// без ключа хранилище сравнивает хеши как есть
+	if phoneIndex == nil {
+		return nil
+	}
+
+	rows, err := tx.Query(`SELECT user_id, phone_hash FROM users WHERE phone_hash IS NOT NULL;`)
+	if err != nil {
+		return err
+	}
+
+	hashes := make(map[int]string)
+	for rows.Next() {
+		var (
+			userID int
+			hash   string
+		)
+		if err := rows.Scan(&userID, &hash); err != nil {
+			rows.Close()
+			return err
+		}
+		hashes[userID] = phoneIndex.Hash(hash)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for userID, hash := range hashes {
+		if _, err := tx.Exec(`UPDATE users SET phone_hash = $1 WHERE user_id = $2;`, hash, userID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// downPhoneHashIndex HMAC необратим: хеши стираются, пользователи находятся только по номеру,
+// пока номер не будет записан заново
+func downPhoneHashIndex(tx *sql.Tx) error {
+	if phoneIndex == nil {
+		return nil
+	}
+
+	_, err := tx.Exec(`UPDATE users SET phone_hash = NULL;`)
+	return err
+}
@@ -0,0 +1,65 @@
+package migration
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/pressly/goose"
+
+	"integ/phone"
+)
+
+func init() {
+	goose.AddMigration(upPhoneNormalized, downPhoneNormalized)
+}
+
+func upPhoneNormalized(tx *sql.Tx) error {
+	if _, err := tx.Exec(`ALTER TABLE users ADD COLUMN phone_normalized TEXT;`); err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(`SELECT user_id, phone_number FROM users;`)
+	if err != nil {
+		return err
+	}
+
+	normalized := make(map[int]string)
+	for rows.Next() {
+		var (
+			userID int
+			number string
+		)
+		if err := rows.Scan(&userID, &number); err != nil {
+			rows.Close()
+			return err
+		}
+
+		n, err := phoneNormalizer.Normalize(number)
+		if errors.Is(err, phone.ErrInvalidNumber) {
+			// такие номера не участвуют в поиске контактов
+			continue
+		}
+		normalized[userID] = n
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for userID, n := range normalized {
+		if _, err := tx.Exec(`UPDATE users SET phone_normalized = $1 WHERE user_id = $2;`, n, userID); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`CREATE INDEX users_phone_normalized_idx ON users (phone_normalized);`)
+	return err
+}
+
+func downPhoneNormalized(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+DROP INDEX IF EXISTS users_phone_normalized_idx;
+ALTER TABLE users DROP COLUMN IF EXISTS phone_normalized;
+`)
+	return err
+}
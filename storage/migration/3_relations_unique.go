@@ -0,0 +1,32 @@
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(upRelationsUnique, downRelationsUnique)
+}
+
+func upRelationsUnique(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+DELETE FROM relations a
+    USING relations b
+WHERE a.user_id = b.user_id
+  AND a.relation_user_id = b.relation_user_id
+  AND a.relation_id > b.relation_id;
+
+ALTER TABLE relations
+    ADD CONSTRAINT relations_user_id_relation_user_id_key UNIQUE (user_id, relation_user_id);
+`)
+	return err
+}
+
+func downRelationsUnique(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+ALTER TABLE relations DROP CONSTRAINT IF EXISTS relations_user_id_relation_user_id_key;
+`)
+	return err
+}
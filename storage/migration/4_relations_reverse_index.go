@@ -0,0 +1,25 @@
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(upRelationsReverseIndex, downRelationsReverseIndex)
+}
+
+func upRelationsReverseIndex(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+CREATE INDEX relations_relation_user_id_idx ON relations (relation_user_id);
+`)
+	return err
+}
+
+func downRelationsReverseIndex(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+DROP INDEX IF EXISTS relations_relation_user_id_idx;
+`)
+	return err
+}
@@ -0,0 +1,25 @@
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(upRelationsAddedAt, downRelationsAddedAt)
+}
+
+func upRelationsAddedAt(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+ALTER TABLE relations ADD COLUMN added_at TIMESTAMPTZ NOT NULL DEFAULT now();
+`)
+	return err
+}
+
+func downRelationsAddedAt(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+ALTER TABLE relations DROP COLUMN IF EXISTS added_at;
+`)
+	return err
+}
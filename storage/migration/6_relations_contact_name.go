@@ -0,0 +1,25 @@
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(upRelationsContactName, downRelationsContactName)
+}
+
+func upRelationsContactName(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+ALTER TABLE relations ADD COLUMN contact_name TEXT NOT NULL DEFAULT '';
+`)
+	return err
+}
+
+func downRelationsContactName(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+ALTER TABLE relations DROP COLUMN IF EXISTS contact_name;
+`)
+	return err
+}
@@ -0,0 +1,35 @@
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(upPendingContacts, downPendingContacts)
+}
+
+func upPendingContacts(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+CREATE TABLE pending_contacts
+(
+    pending_contact_id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    phone_normalized TEXT NOT NULL,
+    contact_name TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (user_id, phone_normalized)
+);
+
+CREATE INDEX pending_contacts_phone_normalized_idx ON pending_contacts (phone_normalized);
+`)
+	return err
+}
+
+func downPendingContacts(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+DROP TABLE IF EXISTS pending_contacts;
+`)
+	return err
+}
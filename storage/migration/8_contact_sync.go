@@ -0,0 +1,30 @@
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(upContactSync, downContactSync)
+}
+
+func upContactSync(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+CREATE TABLE contact_sync
+(
+    user_id INTEGER PRIMARY KEY,
+    version BIGINT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+`)
+	return err
+}
+
+func downContactSync(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+DROP TABLE IF EXISTS contact_sync;
+`)
+	return err
+}
@@ -0,0 +1,38 @@
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/pressly/goose"
+)
+
+func init() {
+	goose.AddMigration(upPhoneVerification, downPhoneVerification)
+}
+
+func upPhoneVerification(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+ALTER TABLE users ADD COLUMN phone_verified_at TIMESTAMPTZ;
+
+CREATE TABLE phone_verifications
+(
+    user_id INTEGER PRIMARY KEY,
+    phone_normalized TEXT NOT NULL,
+    code_hash BYTEA NOT NULL,
+    salt BYTEA NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+`)
+	return err
+}
+
+func downPhoneVerification(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+DROP TABLE IF EXISTS phone_verifications;
+
+ALTER TABLE users DROP COLUMN IF EXISTS phone_verified_at;
+`)
+	return err
+}
@@ -7,10 +7,15 @@ import (
 	"sync"
 
 	"github.com/pressly/goose"
+
+	"integ/phone"
 )
 
 type Tool struct {
-	db *sql.DB
+	db         *sql.DB
+	normalizer *phone.Normalizer
+	hasher     *phone.Hasher
+	index      *phone.Hasher
 }
 
 const (
@@ -19,8 +24,36 @@ const (
 
 var (
 	gooseMu = &sync.Mutex{}
+
+	// phoneNormalizer, phoneHasher и phoneIndex используются миграциями с данными, выставляются под gooseMu
+	phoneNormalizer *phone.Normalizer
+	phoneHasher     *phone.Hasher
+	phoneIndex      *phone.Hasher
 )
 
+// WithPhoneNormalizer задает нормализатор номеров для заполнения phone_normalized,
+// по умолчанию используется регион phone.DefaultRegion
+func WithPhoneNormalizer(normalizer *phone.Normalizer) func(*Tool) {
+	return func(t *Tool) {
+		t.normalizer = normalizer
+	}
+}
+
+// WithPhoneHasher задает ключ для заполнения phone_hash, без него хеши не заполняются
+func WithPhoneHasher(hasher *phone.Hasher) func(*Tool) {
+	return func(t *Tool) {
+		t.hasher = hasher
+	}
+}
+
+// WithPhoneIndex задает ключ слепого индекса, которым переводятся в серверный вид хеши phone_hash,
+// без него хеши остаются как есть, как и в хранилище без шифрования номеров
+func WithPhoneIndex(index *phone.Hasher) func(*Tool) {
+	return func(t *Tool) {
+		t.index = index
+	}
+}
+
 func New(db *sql.DB, opts ...func(*Tool)) *Tool {
 	tool := &Tool{
 		db: db,
@@ -42,6 +75,18 @@ func (t *Tool) runGooseMigration() error {
 	gooseMu.Lock()
 	defer gooseMu.Unlock()
 
+	normalizer := t.normalizer
+	if normalizer == nil {
+		var err error
+		normalizer, err = phone.NewNormalizer(phone.DefaultRegion)
+		if err != nil {
+			return err
+		}
+	}
+	phoneNormalizer = normalizer
+	phoneHasher = t.hasher
+	phoneIndex = t.index
+
 	goose.SetTableName(gooseTableName)
 	goose.SetVerbose(false)
 	goose.SetLogger(logrus.New().WithField("subsys", "database_tool"))
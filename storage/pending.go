@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"integ/entities"
+
+	"github.com/doug-martin/goqu/v9"
+)
+
+// pendingBatchSize ограничивает число строк в одном INSERT (по 4 параметра на строку)
+const pendingBatchSize = 10000
+
+// SavePendingContacts запоминает незарегистрированные номера из контактов пользователя
+func (s *Store) SavePendingContacts(ctx context.Context, contacts []*entities.PendingContact) error {
+	for start := 0; start < len(contacts); start += pendingBatchSize {
+		end := start + pendingBatchSize
+		if end > len(contacts) {
+			end = len(contacts)
+		}
+
+		rows := make([]interface{}, 0, end-start)
+		for _, contact := range contacts[start:end] {
+			sealed, err := s.sealPhone(contact.PhoneNumber)
+			if err != nil {
+				return err
+			}
+			rows = append(rows, goqu.Record{
+				"user_id":      contact.UserID,
+				"phone_number": sealed,
+				"phone_index":  s.phoneIndex(contact.PhoneNumber),
+				"contact_name": contact.ContactName,
+			})
+		}
+
+		insertSQL, args, err := dialect.
+			Insert("pending_contacts").
+			Prepared(true).
+			Rows(rows...).
+			OnConflict(goqu.DoUpdate("user_id, phone_index", goqu.Record{"contact_name": goqu.I("excluded.contact_name")})).
+			ToSQL()
+		if err != nil {
+			return fmt.Errorf("failed to build a query insert pending contacts: %w", err)
+		}
+
+		if _, err := s.Exec(ctx, insertSQL, args...); err != nil {
+			return dbError("failed to execute a query insert pending contacts", err)
+		}
+	}
+
+	return nil
+}
+
+// MaterializePendingContacts превращает отложенные контакты с номером пользователя в связи
+// и удаляет их, возвращает созданные связи. Отложенные контакты загрузивших, которым пользователь
+// не виден, остаются и связями не становятся
+func (s *Store) MaterializePendingContacts(ctx context.Context, user *entities.User) (entities.RelationList, error) {
+	visible := dialect.
+		From("users").
+		Select(goqu.L("1")).
+		Where(
+			goqu.I("users.user_id").Eq(user.UserID),
+			visibleTo(goqu.I("pending_contacts.user_id")),
+		)
+
+	pending := dialect.
+		Delete("pending_contacts").
+		Where(
+			goqu.C("phone_index").Eq(s.phoneIndex(user.PhoneNumber)),
+			goqu.C("user_id").Neq(user.UserID),
+			goqu.L("EXISTS ?", visible),
+		).
+		Returning("user_id", "contact_name")
+
+	insertSQL, args, err := dialect.
+		Insert("relations").
+		Prepared(true).
+		With("pending", pending).
+		Cols("user_id", "relation_user_id", "contact_name").
+		FromQuery(dialect.
+			From("pending").
+			Select("pending.user_id", goqu.Cast(goqu.V(user.UserID), "INTEGER"), "pending.contact_name")).
+		OnConflict(goqu.DoNothing()).
+		Returning("user_id", "relation_user_id", "contact_name").
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build a query materialize pending contacts: %w", err)
+	}
+
+	rows, err := s.Query(ctx, insertSQL, args...)
+	if err != nil {
+		return nil, dbError("failed to execute a query materialize pending contacts", err)
+	}
+	defer rows.Close()
+
+	relations := make(entities.RelationList, 0)
+	for rows.Next() {
+		var relation entities.Relation
+		if err := rows.Scan(&relation.UserID, &relation.RelationUserID, &relation.ContactName); err != nil {
+			return nil, fmt.Errorf("failed to read relations from database: %w", err)
+		}
+		relations = append(relations, &relation)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError("failed to read materialized relations", err)
+	}
+
+	return relations, nil
+}
@@ -0,0 +1,270 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"integ/entities"
+	"integ/keyring"
+	"integ/phone"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+)
+
+// WithPhoneEncryption включает шифрование номеров пользователей: ring шифрует phone_number,
+// index вычисляет слепой индекс phone_index для поиска по номеру.
+// Без опции номера хранятся открыто и phone_index совпадает с номером
+func WithPhoneEncryption(ring *keyring.KeyRing, index *phone.Hasher) func(*Store) {
+	return func(s *Store) {
+		s.ring = ring
+		s.index = index
+	}
+}
+
+// sealPhone значение phone_number для записи
+func (s *Store) sealPhone(number string) (string, error) {
+	if s.ring == nil {
+		return number, nil
+	}
+
+	sealed, err := s.ring.Encrypt([]byte(number))
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt phone number: %w", err)
+	}
+	return sealed, nil
+}
+
+// openPhone номер из phone_number, незашифрованные значения возвращаются как есть
+func (s *Store) openPhone(value string) (string, error) {
+	if !keyring.IsEncrypted(value) {
+		return value, nil
+	}
+	if s.ring == nil {
+		return "", fmt.Errorf("phone number is encrypted: %w", keyring.ErrNoKeys)
+	}
+
+	number, err := s.ring.Decrypt(value)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt phone number: %w", err)
+	}
+	return string(number), nil
+}
+
+// phoneIndex значение phone_index для нормализованного номера
+func (s *Store) phoneIndex(number string) string {
+	if s.index == nil {
+		return number
+	}
+	return s.index.Hash(number)
+}
+
+// phoneIndexes значения phone_index для списка номеров, пустой список - пустой массив, а не NULL
+func (s *Store) phoneIndexes(numbers []string) []string {
+	indexes := make([]string, 0, len(numbers))
+	for _, number := range numbers {
+		indexes = append(indexes, s.phoneIndex(number))
+	}
+	return indexes
+}
+
+// phoneHash значение phone_hash для хеша номера, который считают клиенты. Ключ клиентских хешей
+// есть в каждом клиенте, поэтому хранится HMAC хеша серверным ключом слепого индекса.
+// Пустой хеш записывается как NULL
+func (s *Store) phoneHash(hash string) interface{} {
+	if hash == "" {
+		return nil
+	}
+	return s.phoneIndex(hash)
+}
+
+// findUsersByPhones как findUsersBy по phone_index, но результат индексирован номером
+func (s *Store) findUsersByPhones(ctx context.Context, numbers []string, conds ...exp.Expression) (map[string]*entities.User, error) {
+	return s.findUsersByIndex(ctx, "phone_index", numbers, conds...)
+}
+
+// findUsersByIndex как findUsersBy по колонке со слепым индексом значений,
+// результат индексирован исходным значением
+func (s *Store) findUsersByIndex(ctx context.Context, col string, values []string, conds ...exp.Expression) (map[string]*entities.User, error) {
+	indexes := make([]string, 0, len(values))
+	byIndex := make(map[string]string, len(values))
+	for _, value := range values {
+		index := s.phoneIndex(value)
+		indexes = append(indexes, index)
+		byIndex[index] = value
+	}
+
+	found, err := s.findUsersBy(ctx, col, indexes, conds...)
+	if err != nil {
+		return nil, err
+	}
+
+	users := make(map[string]*entities.User, len(found))
+	for index, user := range found {
+		users[byIndex[index]] = user
+	}
+
+	return users, nil
+}
+
+// keyringPrefixPattern LIKE-шаблон значений, зашифрованных keyring
+const keyringPrefixPattern = "v1.%"
+
+// phoneTable таблица с номером в phone_number, indexed - есть ли слепой индекс phone_index
+type phoneTable struct {
+	name    string
+	id      string
+	indexed bool
+}
+
+// phoneTables таблицы, в которых хранятся номера телефонов
+var phoneTables = []phoneTable{
+	{name: "users", id: "user_id", indexed: true},
+	{name: "pending_contacts", id: "pending_contact_id", indexed: true},
+	{name: "phone_verifications", id: "user_id"},
+}
+
+// ReencryptPhones шифрует номера, записанные открытым текстом, и перешифровывает номера,
+// зашифрованные не основным ключом, во всех таблицах с номерами, возвращает количество измененных строк.
+// Для открытых номеров phone_index пересчитывается из номера, поэтому команда выполняется
+// после включения шифрования и после каждой смены основного ключа
+func (s *Store) ReencryptPhones(ctx context.Context, batchSize int) (int, error) {
+	if s.ring == nil || s.index == nil {
+		return 0, keyring.ErrNoKeys
+	}
+	if batchSize <= 0 {
+		batchSize = phonesBatchSize
+	}
+
+	return s.reencryptPhones(ctx, batchSize, false)
+}
+
+// EncryptPlaintextPhones шифрует только номера, записанные открытым текстом, и пересчитывает их phone_index.
+// Выполняется при запуске: до этого такие номера не находятся по слепому индексу
+func (s *Store) EncryptPlaintextPhones(ctx context.Context, batchSize int) (int, error) {
+	if s.ring == nil || s.index == nil {
+		return 0, keyring.ErrNoKeys
+	}
+	if batchSize <= 0 {
+		batchSize = phonesBatchSize
+	}
+
+	return s.reencryptPhones(ctx, batchSize, true)
+}
+
+func (s *Store) reencryptPhones(ctx context.Context, batchSize int, plaintextOnly bool) (int, error) {
+	updated := 0
+	for _, table := range phoneTables {
+		n, err := s.reencryptTable(ctx, table, batchSize, plaintextOnly)
+		updated += n
+		if err != nil {
+			return updated, fmt.Errorf("%s: %w", table.name, err)
+		}
+	}
+
+	return updated, nil
+}
+
+func (s *Store) reencryptTable(ctx context.Context, table phoneTable, batchSize int, plaintextOnly bool) (int, error) {
+	var (
+		cursor  int
+		updated int
+	)
+	cols := []interface{}{table.id, "phone_number"}
+	if table.indexed {
+		cols = append(cols, "phone_index")
+	}
+
+	for {
+		ds := dialect.
+			From(table.name).
+			Prepared(true).
+			Select(cols...).
+			Where(goqu.C(table.id).Gt(cursor)).
+			Order(goqu.C(table.id).Asc()).
+			Limit(uint(batchSize))
+		if plaintextOnly {
+			ds = ds.Where(goqu.C("phone_number").NotLike(keyringPrefixPattern))
+		}
+
+		selectSQL, args, err := ds.ToSQL()
+		if err != nil {
+			return updated, fmt.Errorf("failed to build a query phones: %w", err)
+		}
+
+		rows, err := s.Query(ctx, selectSQL, args...)
+		if err != nil {
+			return updated, dbError("failed to execute a query phones", err)
+		}
+
+		type phoneRow struct {
+			id    int
+			value string
+			index *string
+		}
+		batch := make([]phoneRow, 0, batchSize)
+		for rows.Next() {
+			var row phoneRow
+			dest := []interface{}{&row.id, &row.value}
+			if table.indexed {
+				dest = append(dest, &row.index)
+			}
+			if err := rows.Scan(dest...); err != nil {
+				rows.Close()
+				return updated, fmt.Errorf("failed to read phone from database: %w", err)
+			}
+			batch = append(batch, row)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return updated, dbError("failed to read phones", err)
+		}
+
+		for _, row := range batch {
+			// у удаленных пользователей номер стерт
+			if row.value == "" || s.ring.IsCurrent(row.value) {
+				continue
+			}
+
+			number, err := s.openPhone(row.value)
+			if err != nil {
+				return updated, fmt.Errorf("%s %d: %w", table.id, row.id, err)
+			}
+			sealed, err := s.sealPhone(number)
+			if err != nil {
+				return updated, err
+			}
+
+			record := goqu.Record{"phone_number": sealed}
+			// у открытых номеров в phone_index лежит нормализованный номер (в phone_number
+			// может остаться номер в исходном виде), NULL - номер не нормализовался, индекса нет
+			if table.indexed && !keyring.IsEncrypted(row.value) && row.index != nil {
+				record["phone_index"] = s.phoneIndex(*row.index)
+			}
+
+			updateSQL, args, err := dialect.
+				Update(table.name).
+				Prepared(true).
+				Set(record).
+				Where(
+					goqu.C(table.id).Eq(row.id),
+					// номер мог измениться, пока выполнялась команда
+					goqu.C("phone_number").Eq(row.value),
+				).
+				ToSQL()
+			if err != nil {
+				return updated, fmt.Errorf("failed to build a query reencrypt phone: %w", err)
+			}
+
+			affected, err := s.Exec(ctx, updateSQL, args...)
+			if err != nil {
+				return updated, dbError("failed to execute a query reencrypt phone", err)
+			}
+			updated += int(affected)
+		}
+
+		if len(batch) < batchSize {
+			return updated, nil
+		}
+		cursor = batch[len(batch)-1].id
+	}
+}
@@ -0,0 +1,212 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"integ/entities"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+)
+
+// visibleTo условие видимости пользователя из таблицы users для viewer:
+// учитываются настройки обнаружения и блокировки в обе стороны,
+// viewer - идентификатор или выражение с ним
+func visibleTo(viewer interface{}) exp.Expression {
+	userID := goqu.I("users.user_id")
+
+	knowsViewer := dialect.From(goqu.T("relations").As("vis")).
+		Select(goqu.L("1")).
+		Where(
+			goqu.I("vis.user_id").Eq(userID),
+			goqu.I("vis.relation_user_id").Eq(viewer),
+		)
+
+	blocked := dialect.From("blocks").
+		Select(goqu.L("1")).
+		Where(goqu.Or(
+			goqu.And(goqu.I("blocks.user_id").Eq(userID), goqu.I("blocks.blocked_user_id").Eq(viewer)),
+			goqu.And(goqu.I("blocks.user_id").Eq(viewer), goqu.I("blocks.blocked_user_id").Eq(userID)),
+		))
+
+	return goqu.And(
+		goqu.Or(
+			goqu.I("users.discoverability").Eq(string(entities.DiscoverableEveryone)),
+			goqu.And(
+				goqu.I("users.discoverability").Eq(string(entities.DiscoverableContacts)),
+				goqu.L("EXISTS ?", knowsViewer),
+			),
+		),
+		goqu.L("NOT EXISTS ?", blocked),
+	)
+}
+
+// checkVisible ErrNotFound, если пользователь uid не виден viewer,
+// чтобы ответ не отличался от ответа для несуществующего пользователя
+func (s *Store) checkVisible(ctx context.Context, viewer, uid int) error {
+	selectSQL, args, err := userTable.
+		Select(goqu.L("1")).
+		Where(
+			goqu.I("users.user_id").Eq(uid),
+			goqu.I("users.erased_at").IsNull(),
+			visibleTo(viewer),
+		).
+		ToSQL()
+	if err != nil {
+		return fmt.Errorf("failed to build a user visibility request: %w", err)
+	}
+
+	rows, err := s.Query(ctx, selectSQL, args...)
+	if err != nil {
+		return dbError("failed to execute a user visibility request", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return dbError("failed to read user visibility", err)
+		}
+		return fmt.Errorf("user %d: %w", uid, ErrNotFound)
+	}
+
+	return nil
+}
+
+// FindDiscoverableUsersByPhones как FindUsersByPhones, но только пользователи,
+// которых uploader может найти с учетом настроек обнаружения и блокировок
+func (s *Store) FindDiscoverableUsersByPhones(ctx context.Context, uploader int, numbers []string) (map[string]*entities.User, error) {
+	return s.findUsersByPhones(ctx, numbers, visibleTo(uploader))
+}
+
+// FindDiscoverableUsersByHashes как FindDiscoverableUsersByPhones, но поиск по хешам номеров
+// от клиентов через phone_hash, результат индексирован хешем клиента
+func (s *Store) FindDiscoverableUsersByHashes(ctx context.Context, uploader int, hashes []string) (map[string]*entities.User, error) {
+	return s.findUsersByIndex(ctx, "phone_hash", hashes, visibleTo(uploader))
+}
+
+func (s *Store) GetPrivacy(ctx context.Context, uid int) (*entities.PrivacySettings, error) {
+	selectSQL, args, err := userTable.
+		Select("discoverability").
+		Where(goqu.C("user_id").Eq(uid)).
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build a query privacy: %w", err)
+	}
+
+	return s.queryPrivacy(ctx, uid, selectSQL, args...)
+}
+
+func (s *Store) UpdatePrivacy(ctx context.Context, uid int, settings entities.PrivacySettings) (*entities.PrivacySettings, error) {
+	updateSQL, args, err := dialect.
+		Update("users").
+		Prepared(true).
+		Set(goqu.Record{"discoverability": string(settings.Discoverability)}).
+		Where(goqu.C("user_id").Eq(uid)).
+		Returning("discoverability").
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build a query update privacy: %w", err)
+	}
+
+	return s.queryPrivacy(ctx, uid, updateSQL, args...)
+}
+
+func (s *Store) queryPrivacy(ctx context.Context, uid int, sql string, args ...interface{}) (*entities.PrivacySettings, error) {
+	rows, err := s.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, dbError("failed to execute a query privacy", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, dbError("failed to read privacy", err)
+		}
+		return nil, fmt.Errorf("user %d: %w", uid, ErrNotFound)
+	}
+
+	var settings entities.PrivacySettings
+	if err := rows.Scan(&settings.Discoverability); err != nil {
+		return nil, fmt.Errorf("failed to read privacy from database: %w", err)
+	}
+
+	return &settings, nil
+}
+
+// BlockUser скрывает пользователей друг от друга, повторная блокировка не считается ошибкой
+func (s *Store) BlockUser(ctx context.Context, uid, blockedUID int) error {
+	insertSQL, args, err := dialect.
+		Insert("blocks").
+		Prepared(true).
+		Rows(goqu.Record{"user_id": uid, "blocked_user_id": blockedUID}).
+		OnConflict(goqu.DoNothing()).
+		ToSQL()
+	if err != nil {
+		return fmt.Errorf("failed to build a query block user: %w", err)
+	}
+
+	if _, err := s.Exec(ctx, insertSQL, args...); err != nil {
+		return dbError("failed to execute a query block user", err)
+	}
+
+	return nil
+}
+
+// UnblockUser снимает блокировку, ErrNotFound если ее не было
+func (s *Store) UnblockUser(ctx context.Context, uid, blockedUID int) error {
+	deleteSQL, args, err := dialect.
+		Delete("blocks").
+		Prepared(true).
+		Where(
+			goqu.C("user_id").Eq(uid),
+			goqu.C("blocked_user_id").Eq(blockedUID),
+		).
+		ToSQL()
+	if err != nil {
+		return fmt.Errorf("failed to build a query unblock user: %w", err)
+	}
+
+	affected, err := s.Exec(ctx, deleteSQL, args...)
+	if err != nil {
+		return dbError("failed to execute a query unblock user", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("block %d -> %d: %w", uid, blockedUID, ErrNotFound)
+	}
+
+	return nil
+}
+
+// FindBlockedUsers пользователи, заблокированные uid
+func (s *Store) FindBlockedUsers(ctx context.Context, uid int) ([]*entities.BlockedUser, error) {
+	selectSQL, args, err := dialect.
+		From("blocks").
+		Prepared(true).
+		Select("blocked_user_id", "created_at").
+		Where(goqu.C("user_id").Eq(uid)).
+		Order(goqu.C("created_at").Asc(), goqu.C("blocked_user_id").Asc()).
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build a query blocked users: %w", err)
+	}
+
+	rows, err := s.Query(ctx, selectSQL, args...)
+	if err != nil {
+		return nil, dbError("failed to execute a query blocked users", err)
+	}
+	defer rows.Close()
+
+	blocked := make([]*entities.BlockedUser, 0)
+	for rows.Next() {
+		var block entities.BlockedUser
+		if err := rows.Scan(&block.BlockedUserID, &block.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to read blocked users from database: %w", err)
+		}
+		blocked = append(blocked, &block)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError("failed to read blocked users", err)
+	}
+
+	return blocked, nil
+}
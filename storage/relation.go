@@ -4,11 +4,12 @@ import (
 	"context"
 	"fmt"
 	"integ/entities"
-	"log"
+	"integ/storage/filtering"
+	"time"
 
 	"github.com/doug-martin/goqu/v9"
 	_ "github.com/doug-martin/goqu/v9/dialect/postgres"
-	"github.com/jackc/pgx/v5"
+	"github.com/doug-martin/goqu/v9/exp"
 )
 
 var (
@@ -30,9 +31,13 @@ var (
 
 	friendsReq  = dialect.From("relations").InnerJoin(goqu.T("users"), goqu.On(goqu.Ex{"relations.relation_user_id": goqu.I("users.user_id")})).Prepared(true)
 	friendsCols = []interface{}{
+		"relations.relation_id",
 		"relations.user_id",
 		"relations.relation_user_id",
+		"users.name",
 		"users.phone_number",
+		"relations.contact_name",
+		"relations.added_at",
 	}
 )
 
@@ -49,167 +54,399 @@ type RelationRaw struct {
 }
 
 type FriendsRaw struct {
+	RelationID     int
 	UserID         int
 	RelationUserID int
+	Name           string
 	PhoneNumber    string
+	ContactName    string
+	AddedAt        time.Time
 }
 
+// FindUserByPhone ищет пользователя с подтвержденным нормализованным номером по слепому индексу
 func (s *Store) FindUserByPhone(ctx context.Context, number string) (*entities.User, error) {
 	selectSQL, args, err := userTable.
 		Select(userCols...).
-		Where(goqu.C("phone_number").Eq(number)).
+		Where(
+			goqu.C("phone_index").Eq(s.phoneIndex(number)),
+			goqu.C("phone_verified_at").IsNotNull(),
+		).
 		Limit(uint(1)).
 		ToSQL()
 	if err != nil {
-		return nil, fmt.Errorf("failed to build a query user")
+		return nil, fmt.Errorf("failed to build a query user: %w", err)
 	}
 
-	if ctx.Value("tx") != nil {
-		t := ctx.Value("tx").(*pgx.Tx)
-		tx := *t
+	rows, err := s.Query(ctx, selectSQL, args...)
+	if err != nil {
+		return nil, dbError("failed to execute a query user", err)
+	}
+	defer rows.Close()
 
-		rows, err := tx.Query(ctx, selectSQL, args...)
+	userRaws := make([]*UserRaw, 0)
+	for rows.Next() {
+		userRaw, err := scanUser(rows)
 		if err != nil {
-			return nil, fmt.Errorf("failed to execute a query user")
+			return nil, fmt.Errorf("failed to read user from database: %w", err)
 		}
+		userRaws = append(userRaws, userRaw)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError("failed to read user", err)
+	}
+
+	if len(userRaws) == 0 {
+		return nil, fmt.Errorf("user by phone: %w", ErrNotFound)
+	}
+
+	return s.buildUser(userRaws[0])
+}
+
+// phonesBatchSize ограничивает размер массива номеров в одном запросе
+const phonesBatchSize = 5000
 
-		defer rows.Close()
+// FindUsersByPhones ищет пользователей с подтвержденными нормализованными номерами,
+// результат индексирован нормализованным номером
+func (s *Store) FindUsersByPhones(ctx context.Context, numbers []string) (map[string]*entities.User, error) {
+	return s.findUsersByPhones(ctx, numbers)
+}
 
-		log.Print("in transaction")
+// findUsersBy ищет пользователей с подтвержденными номерами по значениям колонки col,
+// результат индексирован значением колонки
+func (s *Store) findUsersBy(ctx context.Context, col string, values []string, conds ...exp.Expression) (map[string]*entities.User, error) {
+	users := make(map[string]*entities.User, len(values))
 
-		userRaws := make([]*UserRaw, 0)
+	for start := 0; start < len(values); start += phonesBatchSize {
+		end := start + phonesBatchSize
+		if end > len(values) {
+			end = len(values)
+		}
+
+		// goqu раскрывает слайс в список параметров, поэтому IN, а не ANY
+		selectSQL, args, err := userTable.
+			Select(append(userCols, col)...).
+			Where(
+				goqu.C(col).In(values[start:end]),
+				goqu.C("phone_verified_at").IsNotNull(),
+			).
+			Where(conds...).
+			ToSQL()
+		if err != nil {
+			return nil, fmt.Errorf("failed to build a query users by phones: %w", err)
+		}
+
+		rows, err := s.Query(ctx, selectSQL, args...)
+		if err != nil {
+			return nil, dbError("failed to execute a query users by phones", err)
+		}
 
 		for rows.Next() {
-			userRaw, err := scanUser(rows)
+			var (
+				userRaw UserRaw
+				key     string
+			)
+			err := rows.Scan(
+				&userRaw.UserID,
+				&userRaw.Name,
+				&userRaw.PhoneNumber,
+				&key,
+			)
 			if err != nil {
-				return nil, fmt.Errorf("failed to read user from database")
+				rows.Close()
+				return nil, fmt.Errorf("failed to read user from database: %w", err)
 			}
-			userRaws = append(userRaws, userRaw)
+			user, err := s.buildUser(&userRaw)
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+			users[key] = user
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, dbError("failed to read users by phones", err)
 		}
+	}
+
+	return users, nil
+}
+
+// relationsBatchSize ограничивает число строк в одном INSERT (по 3 параметра на строку)
+const relationsBatchSize = 10000
 
-		if len(userRaws) == 0 {
-			return nil, err
+// SaveRelations сохраняет связи, для уже существующих обновляет имя контакта,
+// возвращает количество добавленных связей
+func (s *Store) SaveRelations(ctx context.Context, relations entities.RelationList) (int, error) {
+	relations = uniqueRelations(relations)
+	created := 0
+
+	for start := 0; start < len(relations); start += relationsBatchSize {
+		end := start + relationsBatchSize
+		if end > len(relations) {
+			end = len(relations)
 		}
 
-		user := buildUser(userRaws[0])
+		rows := make([]interface{}, 0, end-start)
+		for _, relation := range relations[start:end] {
+			rows = append(rows, goqu.Record{
+				"user_id":          relation.UserID,
+				"relation_user_id": relation.RelationUserID,
+				"contact_name":     relation.ContactName,
+			})
+		}
 
-		return user, nil
+		// xmax = 0 только у вставленных строк, обновленные возвращаются с false,
+		// строки без изменений не возвращаются вовсе
+		insertSQL, args, err := dialect.
+			Insert("relations").
+			Prepared(true).
+			Rows(rows...).
+			OnConflict(goqu.
+				DoUpdate("user_id, relation_user_id", goqu.Record{"contact_name": goqu.I("excluded.contact_name")}).
+				Where(goqu.I("relations.contact_name").Neq(goqu.I("excluded.contact_name")))).
+			Returning(goqu.L("xmax = 0")).
+			ToSQL()
+		if err != nil {
+			return 0, fmt.Errorf("failed to build a query insert relations: %w", err)
+		}
+
+		inserted, err := s.queryInserted(ctx, insertSQL, args...)
+		if err != nil {
+			return 0, err
+		}
 
+		created += inserted
 	}
 
-	rows, err := s.Query(ctx, selectSQL, args...)
+	return created, nil
+}
+
+func (s *Store) queryInserted(ctx context.Context, sql string, args ...interface{}) (int, error) {
+	rows, err := s.Query(ctx, sql, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute a query user")
+		return 0, dbError("failed to execute a query insert relations", err)
 	}
-	userRaws := make([]*UserRaw, 0)
+	defer rows.Close()
+
+	inserted := 0
 	for rows.Next() {
-		userRaw, err := scanUser(rows)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read user from database")
+		var isInsert bool
+		if err := rows.Scan(&isInsert); err != nil {
+			return 0, fmt.Errorf("failed to read inserted relations: %w", err)
+		}
+		if isInsert {
+			inserted++
 		}
-		userRaws = append(userRaws, userRaw)
 	}
+	if err := rows.Err(); err != nil {
+		return 0, dbError("failed to read inserted relations", err)
+	}
+
+	return inserted, nil
+}
 
-	user := buildUser(userRaws[0])
+// uniqueRelations убирает повторы пары (user_id, relation_user_id), оставляя последнюю,
+// ON CONFLICT DO UPDATE не может обновить одну строку дважды в одном запросе
+func uniqueRelations(relations entities.RelationList) entities.RelationList {
+	type key struct{ userID, relationUserID int }
+
+	index := make(map[key]int, len(relations))
+	unique := make(entities.RelationList, 0, len(relations))
+	for _, relation := range relations {
+		k := key{relation.UserID, relation.RelationUserID}
+		if i, ok := index[k]; ok {
+			unique[i] = relation
+			continue
+		}
+		index[k] = len(unique)
+		unique = append(unique, relation)
+	}
 
-	return user, nil
+	return unique
 }
 
-func (s *Store) SaveRelation(ctx context.Context, relation *entities.Relation) error {
-	createRelationSQL, args, err := goqu.
-		Insert("relations").
-		Cols("user_id", "relation_user_id").
-		Vals(goqu.Vals{relation.UserID, relation.RelationUserID}).
-		ToSQL()
-	if err != nil {
-		return fmt.Errorf("failed to build a query insert relation")
+// Поля сортировки списка друзей, по номеру не сортируется: номера хранятся зашифрованными
+const (
+	FriendsSortDefault = ""
+	FriendsSortName    = "name"
+	FriendsSortAddedAt = "added_at"
+)
+
+var friendsSortCols = map[string]string{
+	FriendsSortDefault: "relations.relation_id",
+	FriendsSortName:    "users.name",
+	FriendsSortAddedAt: "relations.added_at",
+}
+
+// FriendsFilterColumns поля, по которым разрешено фильтровать список друзей
+var FriendsFilterColumns = filtering.Whitelist{
+	"name":             {Name: "users.name", Type: filtering.TypeString},
+	"relation_user_id": {Name: "relations.relation_user_id", Type: filtering.TypeInt},
+	"added_at":         {Name: "relations.added_at", Type: filtering.TypeTime},
+}
+
+// FriendsQuery параметры выборки списка друзей.
+// Filter строится по колонкам из FriendsFilterColumns,
+// PhoneNumber - точное совпадение нормализованного номера, номера зашифрованы,
+// поэтому сравнивается только слепой индекс,
+// Cursor - relation_id последней строки предыдущей страницы, 0 для первой страницы,
+// Limit 0 - без ограничения
+type FriendsQuery struct {
+	Filter      filtering.Expression
+	PhoneNumber string
+	Sort        string
+	Desc        bool
+	Cursor      int
+	Limit       uint
+}
+
+func (s *Store) FindFriends(ctx context.Context, uid int, query FriendsQuery) ([]*entities.Friend, error) {
+	sortCol, ok := friendsSortCols[query.Sort]
+	if !ok {
+		return nil, fmt.Errorf("unknown friends sort %q: %w", query.Sort, ErrInvalid)
 	}
 
-	if ctx.Value("tx") != nil {
-		t := ctx.Value("tx").(*pgx.Tx)
-		tx := *t
+	ds := friendsReq.
+		Select(friendsCols...).
+		Where(
+			goqu.T("relations").Col("user_id").Eq(uid),
+			visibleTo(uid),
+		)
 
-		_, err = tx.Exec(ctx, createRelationSQL, args...)
+	if query.Filter != nil {
+		filterExp, err := query.Filter.GetExp()
 		if err != nil {
-			return fmt.Errorf("failed to build a query insert relation")
+			return nil, fmt.Errorf("failed to build a friends filter: %w: %s", ErrInvalid, err)
+		}
+		ds = ds.Where(filterExp)
+	}
+	if query.PhoneNumber != "" {
+		ds = ds.Where(goqu.I("users.phone_index").Eq(s.phoneIndex(query.PhoneNumber)))
+	}
+
+	if query.Cursor > 0 {
+		if query.Sort != FriendsSortDefault {
+			if err := s.checkFriendsCursor(ctx, uid, query.Cursor); err != nil {
+				return nil, err
+			}
+		}
+		ds = ds.Where(friendsKeyset(uid, sortCol, query.Cursor, query.Desc))
+	}
+
+	order := []exp.IdentifierExpression{goqu.I(sortCol)}
+	if query.Sort != FriendsSortDefault {
+		order = append(order, goqu.I("relations.relation_id"))
+	}
+	for _, col := range order {
+		if query.Desc {
+			ds = ds.OrderAppend(col.Desc())
+		} else {
+			ds = ds.OrderAppend(col.Asc())
 		}
-		return nil
 	}
 
-	// реализация без транзакций
-	_, err = s.Exec(ctx, createRelationSQL, args...)
+	if query.Limit > 0 {
+		ds = ds.Limit(query.Limit)
+	}
+
+	selectSQL, args, err := ds.ToSQL()
+
 	if err != nil {
-		return fmt.Errorf("failed to build a query insert relation")
+		return nil, fmt.Errorf("failed to build a friends request: %w", err)
 	}
+	return s.queryFriends(ctx, selectSQL, args...)
+}
 
-	//реализация с транзакциями
-	// _, err = s.db.ExecWithTransaction(ctx, createRelationSQL, pgx.TxOptions{}, args...)
-	// if err != nil {
-	// 	return fmt.Errorf("failed to build a query insert relation")
-	// }
+// friendsKeyset условие "строго после курсора" с учетом сортировки,
+// значение поля сортировки для курсора берется из строки relations uid с relation_id = cursor
+func friendsKeyset(uid int, sortCol string, cursor int, desc bool) exp.Expression {
+	op := ">"
+	if desc {
+		op = "<"
+	}
 
-	return nil
+	relationID := goqu.I("relations.relation_id")
+	if sortCol == friendsSortCols[FriendsSortDefault] {
+		return goqu.L("? "+op+" ?", relationID, cursor)
+	}
+
+	cursorValue := friendsReq.
+		Select(sortCol).
+		Where(
+			goqu.I("relations.relation_id").Eq(cursor),
+			goqu.I("relations.user_id").Eq(uid),
+		)
+
+	return goqu.L("(?, ?) "+op+" (?, ?)", goqu.I(sortCol), relationID, cursorValue, cursor)
 }
 
-func (s *Store) FindFriends(ctx context.Context, uid int) ([]*entities.Friend, error) {
+// checkFriendsCursor ErrInvalidCursor, если связи cursor у uid нет:
+// без нее значение сортировки курсора не определено и страница была бы пустой
+func (s *Store) checkFriendsCursor(ctx context.Context, uid, cursor int) error {
 	selectSQL, args, err := friendsReq.
-		Select(friendsCols...).
-		Where(goqu.T("relations").Col("user_id").Eq(uid)).
+		Select(goqu.L("1")).
+		Where(
+			goqu.I("relations.relation_id").Eq(cursor),
+			goqu.I("relations.user_id").Eq(uid),
+		).
 		ToSQL()
-
 	if err != nil {
-		return nil, fmt.Errorf("failed to build a friends request")
+		return fmt.Errorf("failed to build a friends cursor request: %w", err)
 	}
+
 	rows, err := s.Query(ctx, selectSQL, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute a friends request")
+		return dbError("failed to execute a friends cursor request", err)
 	}
-	friendsRaw := make([]*FriendsRaw, 0)
-	for rows.Next() {
-		friendRaw, err := scanFriends(rows)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read friends from database")
-		}
-		friendsRaw = append(friendsRaw, friendRaw)
-	}
-
-	friends := make([]*entities.Friend, 0)
+	defer rows.Close()
 
-	for _, frnd := range friendsRaw {
-		friend := buildFriends(frnd)
-		friends = append(friends, friend)
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return dbError("failed to read friends cursor", err)
+		}
+		return fmt.Errorf("friends cursor %d: %w", cursor, ErrInvalidCursor)
 	}
 
-	return friends, nil
+	return nil
 }
 
 func (s *Store) GetName(ctx context.Context, uid int) (string, error) {
 	selectSQL, args, err := userTable.
 		Select(userCols...).
-		Where(goqu.C("user_id").Eq(uid)).
+		Where(
+			goqu.C("user_id").Eq(uid),
+			goqu.C("erased_at").IsNull(),
+		).
 		Limit(uint(1)).
 		ToSQL()
 
 	if err != nil {
-		return "", fmt.Errorf("failed to build a name request")
+		return "", fmt.Errorf("failed to build a name request: %w", err)
 	}
 
 	rows, err := s.Query(ctx, selectSQL, args...)
 	if err != nil {
-		return "", fmt.Errorf("failed to execute a name request")
+		return "", dbError("failed to execute a name request", err)
 	}
+	defer rows.Close()
 
 	userRaws := make([]*UserRaw, 0)
 	for rows.Next() {
 		userRaw, err := scanUser(rows)
 		if err != nil {
-			return "", fmt.Errorf("failed to read user from database")
+			return "", fmt.Errorf("failed to read user from database: %w", err)
 		}
 		userRaws = append(userRaws, userRaw)
 	}
+	if err := rows.Err(); err != nil {
+		return "", dbError("failed to read name", err)
+	}
+
+	if len(userRaws) == 0 {
+		return "", fmt.Errorf("user %d: %w", uid, ErrNotFound)
+	}
 
-	user := buildUser(userRaws[0])
-	return user.Name, nil
+	return userRaws[0].Name, nil
 }
 
 func scanUser(rows Row) (*UserRaw, error) {
@@ -245,9 +482,13 @@ func scanUser(rows Row) (*UserRaw, error) {
 func scanFriends(rows Row) (*FriendsRaw, error) {
 	friendsRaw := FriendsRaw{}
 	err := rows.Scan(
+		&friendsRaw.RelationID,
 		&friendsRaw.UserID,
 		&friendsRaw.RelationUserID,
+		&friendsRaw.Name,
 		&friendsRaw.PhoneNumber,
+		&friendsRaw.ContactName,
+		&friendsRaw.AddedAt,
 	)
 
 	if err != nil {
@@ -257,12 +498,17 @@ func scanFriends(rows Row) (*FriendsRaw, error) {
 	return &friendsRaw, nil
 }
 
-func buildUser(userRaw *UserRaw) *entities.User {
+func (s *Store) buildUser(userRaw *UserRaw) (*entities.User, error) {
+	number, err := s.openPhone(userRaw.PhoneNumber)
+	if err != nil {
+		return nil, fmt.Errorf("user %d: %w", userRaw.UserID, err)
+	}
+
 	return &entities.User{
 		UserID:      userRaw.UserID,
 		Name:        userRaw.Name,
-		PhoneNumber: userRaw.PhoneNumber,
-	}
+		PhoneNumber: number,
+	}, nil
 }
 
 // func buildRelations(relationRaw *RelationRaw) *entities.Relation {
@@ -272,10 +518,19 @@ func buildUser(userRaw *UserRaw) *entities.User {
 // 	}
 // }
 
-func buildFriends(friendRaw *FriendsRaw) *entities.Friend {
+func (s *Store) buildFriends(friendRaw *FriendsRaw) (*entities.Friend, error) {
+	number, err := s.openPhone(friendRaw.PhoneNumber)
+	if err != nil {
+		return nil, fmt.Errorf("user %d: %w", friendRaw.RelationUserID, err)
+	}
+
 	return &entities.Friend{
+		RelationID:     friendRaw.RelationID,
 		UserID:         friendRaw.UserID,
 		RelationUserID: friendRaw.RelationUserID,
-		PhoneNumber:    friendRaw.PhoneNumber,
-	}
+		Name:           friendRaw.Name,
+		PhoneNumber:    number,
+		ContactName:    friendRaw.ContactName,
+		AddedAt:        friendRaw.AddedAt,
+	}, nil
 }
@@ -2,105 +2,111 @@ package storage
 
 import (
 	"context"
-	"log"
+	"time"
+
+	"integ/keyring"
+	"integ/phone"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/sirupsen/logrus"
 )
 
 type DB interface {
 	ExecContext(ctx context.Context, sql string, args ...interface{}) (Result, error)
-	// ExecWithTransaction(ctx context.Context, sql string, txOptions pgx.TxOptions, args ...interface{}) (Result, error)
 	QueryContext(ctx context.Context, sql string, args ...interface{}) (Rows, error)
-	StartTX(ctx context.Context, opts pgx.TxOptions) (pgx.Tx, error)
-	EndTX(ctx context.Context, tx pgx.Tx) error
+	BeginTx(ctx context.Context, opts pgx.TxOptions) (pgx.Tx, error)
 }
 
 type Store struct {
 	db      DB
+	tx      *TxManager
 	log     logrus.FieldLogger
+	ring    *keyring.KeyRing
+	index   *phone.Hasher
 	CloseFn func(ctx context.Context) error
 }
 
-func New(ctx context.Context, connConf *pgx.ConnConfig, log logrus.FieldLogger) (*Store, error) {
-	conn, err := pgx.ConnectConfig(ctx, connConf)
-
+func New(ctx context.Context, poolConf *pgxpool.Config, log logrus.FieldLogger, opts ...func(*Store)) (*Store, error) {
+	pool, err := pgxpool.NewWithConfig(ctx, poolConf)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Store{
-		db:  &PGConn{conn: conn},
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, err
+	}
+
+	db := &PGPool{pool: pool}
+
+	store := &Store{
+		db:  db,
+		tx:  NewTxManager(db, log),
 		log: log,
 
-		CloseFn: conn.Close,
-	}, nil
-}
+		CloseFn: func(ctx context.Context) error {
+			pool.Close()
+			return nil
+		},
+	}
+	for _, opt := range opts {
+		opt(store)
+	}
 
-type PGConn struct {
-	conn *pgx.Conn
+	return store, nil
 }
 
-func (p *PGConn) ExecContext(ctx context.Context, sql string, args ...interface{}) (Result, error) {
-	return p.conn.Exec(ctx, sql, args...)
+// PGPool реализация DB поверх пула соединений, безопасна для конкурентного использования
+type PGPool struct {
+	pool *pgxpool.Pool
 }
 
-func (p *PGConn) QueryContext(ctx context.Context, sql string, args ...any) (Rows, error) {
-	return p.conn.Query(ctx, sql, args...)
+func (p *PGPool) ExecContext(ctx context.Context, sql string, args ...interface{}) (Result, error) {
+	return p.pool.Exec(ctx, sql, args...)
 }
 
-// func (p *PGConn) ExecWithTransaction(ctx context.Context, sql string, txOptions pgx.TxOptions, args ...interface{}) (Result, error) {
-// 	tx, err := p.conn.BeginTx(ctx, pgx.TxOptions{})
-
-// 	if err != nil {
-// 		log.Print(err)
-// 		return nil, err
-// 	}
-// 	defer func() {
-// 		if err != nil {
-// 			log.Print(err)
-// 			log.Print("Transaction Rollbacked")
-// 			tx.Rollback(ctx)
-// 		} else {
-// 			tx.Commit(ctx)
-// 			log.Print("Transaction Completed")
-// 		}
-// 	}()
-
-// 	return tx.Exec(ctx, sql, args...)
-// }
+func (p *PGPool) QueryContext(ctx context.Context, sql string, args ...any) (Rows, error) {
+	return p.pool.Query(ctx, sql, args...)
+}
 
-func (p *PGConn) StartTX(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
-	log.Print("Transaction opened (in PGConn)")
-	tx, err := p.conn.BeginTx(ctx, pgx.TxOptions{})
+func (p *PGPool) Stat() *PoolStat {
+	stat := p.pool.Stat()
 
-	if err != nil {
-		log.Print(err)
-		return nil, err
+	return &PoolStat{
+		AcquireCount:            stat.AcquireCount(),
+		AcquireDuration:         stat.AcquireDuration(),
+		AcquiredConns:           stat.AcquiredConns(),
+		CanceledAcquireCount:    stat.CanceledAcquireCount(),
+		ConstructingConns:       stat.ConstructingConns(),
+		EmptyAcquireCount:       stat.EmptyAcquireCount(),
+		IdleConns:               stat.IdleConns(),
+		MaxConns:                stat.MaxConns(),
+		TotalConns:              stat.TotalConns(),
+		NewConnsCount:           stat.NewConnsCount(),
+		MaxLifetimeDestroyCount: stat.MaxLifetimeDestroyCount(),
+		MaxIdleDestroyCount:     stat.MaxIdleDestroyCount(),
 	}
-	// defer func() {
-	// 	if err != nil {
-	// 		log.Print(err)
-	// 		log.Print("Transaction Rollbacked")
-	// 		tx.Rollback(ctx)
-	// 	} else {
-	// 		tx.Commit(ctx)
-	// 		log.Print("Transaction Completed")
-	// 	}
-	// }()
-
-	return tx, nil
 }
 
-func (p *PGConn) EndTX(ctx context.Context, tx pgx.Tx) error {
-
-	err := tx.Commit(ctx)
-	if err != nil {
-		return err
-	}
-	log.Print("Transaction Completed (in PGConn)")
+// PoolStat снимок статистики пула соединений
+type PoolStat struct {
+	AcquireCount            int64         `json:"acquire_count"`
+	AcquireDuration         time.Duration `json:"acquire_duration"`
+	AcquiredConns           int32         `json:"acquired_conns"`
+	CanceledAcquireCount    int64         `json:"canceled_acquire_count"`
+	ConstructingConns       int32         `json:"constructing_conns"`
+	EmptyAcquireCount       int64         `json:"empty_acquire_count"`
+	IdleConns               int32         `json:"idle_conns"`
+	MaxConns                int32         `json:"max_conns"`
+	TotalConns              int32         `json:"total_conns"`
+	NewConnsCount           int64         `json:"new_conns_count"`
+	MaxLifetimeDestroyCount int64         `json:"max_lifetime_destroy_count"`
+	MaxIdleDestroyCount     int64         `json:"max_idle_destroy_count"`
+}
 
-	return nil
+func (p *PGPool) BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+	return p.pool.BeginTx(ctx, txOptions)
 }
 
 type Row interface {
@@ -111,64 +117,51 @@ type Rows interface {
 	Scan(...interface{}) error
 	Next() bool
 	Close()
+	// Err ошибка выполнения запроса, pgx возвращает ее только здесь, после Next
+	Err() error
 }
 
 type Result interface {
 	RowsAffected() int64
 }
 
-func (s *Store) Query(ctx context.Context, sql string, args ...interface{}) (Rows, error) {
-	return s.db.QueryContext(ctx, sql, args...)
-}
-
-func (s *Store) Exec(ctx context.Context, sql string, args ...interface{}) (int64, error) {
-
-	result, err := s.db.ExecContext(ctx, sql, args...)
-
-	if err != nil {
-		return 0, err
+// PoolStat статистика пула соединений, false если хранилище работает не через пул
+func (s *Store) PoolStat() (*PoolStat, bool) {
+	pool, ok := s.db.(*PGPool)
+	if !ok {
+		return nil, false
 	}
-	return result.RowsAffected(), nil
+
+	return pool.Stat(), true
 }
 
-func (s *Store) StartTX(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
-	tx, err := s.db.StartTX(ctx, pgx.TxOptions{})
+// WithinTx выполняет fn в транзакции, см. TxManager.WithinTx
+func (s *Store) WithinTx(ctx context.Context, opts pgx.TxOptions, fn func(ctx context.Context) error) error {
+	return s.tx.WithinTx(ctx, opts, fn)
+}
 
-	if err != nil {
-		log.Print(err)
-		return nil, err
+// Query выполняет запрос в транзакции из контекста, если она есть
+func (s *Store) Query(ctx context.Context, sql string, args ...interface{}) (Rows, error) {
+	if tx, ok := txFromContext(ctx); ok {
+		return tx.Query(ctx, sql, args...)
 	}
-	// defer func() {
-	// 	if err != nil {
-	// 		log.Print(err)
-	// 		log.Print("Transaction Rollbacked")
-	// 		tx.Rollback(ctx)
-	// 	} else {
-	// 		tx.Commit(ctx)
-	// 		log.Print("Transaction Completed")
-	// 	}
-	// }()
-
-	return tx, nil
+	return s.db.QueryContext(ctx, sql, args...)
 }
 
-func (s *Store) EndTX(ctx context.Context, tx pgx.Tx) error {
-
-	log.Print("Transaction ended (in Store)")
+// Exec выполняет запрос в транзакции из контекста, если она есть
+func (s *Store) Exec(ctx context.Context, sql string, args ...interface{}) (int64, error) {
+	var (
+		result Result
+		err    error
+	)
+	if tx, ok := txFromContext(ctx); ok {
+		result, err = tx.Exec(ctx, sql, args...)
+	} else {
+		result, err = s.db.ExecContext(ctx, sql, args...)
+	}
 
-	err := tx.Commit(ctx)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	log.Print("Transaction Completed (in Store)")
-
-	return nil
-}
-
-func (s *Store) ExecContext(ctx context.Context, sql string, args ...interface{}) (Result, error) {
-	return nil, nil
-}
-
-func (s *Store) QueryContext(ctx context.Context, sql string, args ...any) (Rows, error) {
-	return nil, nil
+	return result.RowsAffected(), nil
 }
@@ -3,11 +3,14 @@ package storage
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"integ/entities"
 	"regexp"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/sirupsen/logrus"
 )
 
@@ -39,6 +42,10 @@ func (r *RowsMock) Close() {
 	r.rows.Close()
 }
 
+func (r *RowsMock) Err() error {
+	return r.rows.Err()
+}
+
 func (d *DBMock) ExecContext(ctx context.Context, sql string, args ...interface{}) (Result, error) {
 	return func() (Result, error) {
 		res, err := d.db.ExecContext(ctx, sql, args...)
@@ -63,37 +70,8 @@ func (d *DBMock) QueryContext(ctx context.Context, sql string, args ...interface
 	}()
 }
 
-func (d *DBMock) ExecWithTransaction(ctx context.Context, sql string, txOptions pgx.TxOptions, args ...interface{}) (Result, error) {
-	// return func() (Rows, error) {
-	// 	r, err := d.db.QueryContext(ctx, sql, args...)
-	// 	if err != nil {
-	// 		return nil, err
-	// 	}
-	// 	return &RowsMock{rows: r}, nil
-	// }()
-	return nil, nil
-}
-
-func (d *DBMock) StartTX(ctx context.Context, opts pgx.TxOptions) (pgx.Tx, error) {
-	// return func() (Rows, error) {
-	// 	r, err := d.db.QueryContext(ctx, sql, args...)
-	// 	if err != nil {]
-	// 		return nil, err
-	// 	}
-	// 	return &RowsMock{rows: r}, nil
-	// }()
-	return nil, nil
-}
-
-func (d *DBMock) EndTX(ctx context.Context, tx pgx.Tx) error {
-	// return func() (Rows, error) {
-	// 	r, err := d.db.QueryContext(ctx, sql, args...)
-	// 	if err != nil {]
-	// 		return nil, err
-	// 	}
-	// 	return &RowsMock{rows: r}, nil
-	// }()
-	return nil
+func (d *DBMock) BeginTx(ctx context.Context, opts pgx.TxOptions) (pgx.Tx, error) {
+	return nil, errors.New("transactions are not supported by DBMock")
 }
 
 func TestStore_FindUserByPhone(t *testing.T) {
@@ -106,7 +84,7 @@ func TestStore_FindUserByPhone(t *testing.T) {
 	rows := sqlmock.NewRows([]string{"id", "name", "phone_number"}).
 		AddRow("1", "Пользователь", "+793455555")
 
-	mock.ExpectQuery(regexp.QuoteMeta(`SELECT "user_id", "name", "phone_number" FROM "users" WHERE ("phone_number" = $1) LIMIT $2`)).
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT "user_id", "name", "phone_number" FROM "users" WHERE (("phone_index" = $1) AND ("phone_verified_at" IS NOT NULL)) LIMIT $2`)).
 		WithArgs("+793455555", 1).
 		WillReturnRows(rows)
 
@@ -130,3 +108,203 @@ func TestStore_FindUserByPhone(t *testing.T) {
 	}
 
 }
+
+func TestStore_FindUsersByPhones(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("Ошибка %s, создание мока", err)
+	}
+
+	rows := sqlmock.NewRows([]string{"user_id", "name", "phone_number", "phone_index"}).
+		AddRow(2, "Мама", "+79830000002", "+79830000002")
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT "user_id", "name", "phone_number", "phone_index" FROM "users" WHERE (("phone_index" IN ($1, $2)) AND ("phone_verified_at" IS NOT NULL))`)).
+		WithArgs("+79830000002", "+79830000003").
+		WillReturnRows(rows)
+
+	store := Store{
+		db:  &DBMock{db: db},
+		log: logrus.StandardLogger(),
+		CloseFn: func(ctx context.Context) error {
+			return db.Close()
+		},
+	}
+
+	defer store.CloseFn(context.Background())
+
+	users, err := store.FindUsersByPhones(context.Background(), []string{"+79830000002", "+79830000003"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(users) != 1 || users["+79830000002"] == nil || users["+79830000002"].UserID != 2 {
+		t.Fatalf("ожидался один пользователь с номером +79830000002, получено %v", users)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStore_SaveRelations(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("Ошибка %s, создание мока", err)
+	}
+
+	rows := sqlmock.NewRows([]string{"?column?"}).
+		AddRow(true).
+		AddRow(false)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "relations" ("contact_name", "relation_user_id", "user_id") VALUES ($1, $2, $3), ($4, $5, $6), ($7, $8, $9) `+
+		`ON CONFLICT (user_id, relation_user_id) DO UPDATE SET "contact_name"="excluded"."contact_name" WHERE ("relations"."contact_name" != "excluded"."contact_name") `+
+		`RETURNING xmax = 0`)).
+		WithArgs("Мама", 2, 1, "", 3, 1, "Брат", 4, 1).
+		WillReturnRows(rows)
+
+	store := Store{
+		db:  &DBMock{db: db},
+		log: logrus.StandardLogger(),
+		CloseFn: func(ctx context.Context) error {
+			return db.Close()
+		},
+	}
+
+	defer store.CloseFn(context.Background())
+
+	created, err := store.SaveRelations(context.Background(), entities.RelationList{
+		{UserID: 1, RelationUserID: 2, ContactName: "Мама"},
+		{UserID: 1, RelationUserID: 3},
+		{UserID: 1, RelationUserID: 4, ContactName: "Друг"},
+		{UserID: 1, RelationUserID: 4, ContactName: "Брат"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if created != 1 {
+		t.Fatalf("должна была добавиться одна связь, добавлено %d", created)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStore_SaveRelationsRowsErr(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("Ошибка %s, создание мока", err)
+	}
+
+	// ошибка выполнения приходит не из Query, а при чтении строк
+	rows := sqlmock.NewRows([]string{"?column?"}).
+		AddRow(true).
+		AddRow(true).
+		RowError(1, &pgconn.PgError{Code: pgUniqueViolation, Message: "duplicate key value"})
+
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "relations"`)).
+		WillReturnRows(rows)
+
+	store := Store{
+		db:  &DBMock{db: db},
+		log: logrus.StandardLogger(),
+		CloseFn: func(ctx context.Context) error {
+			return db.Close()
+		},
+	}
+
+	defer store.CloseFn(context.Background())
+
+	_, err = store.SaveRelations(context.Background(), entities.RelationList{
+		{UserID: 1, RelationUserID: 2, ContactName: "Мама"},
+		{UserID: 1, RelationUserID: 3, ContactName: "Папа"},
+	})
+	if !errors.Is(err, ErrConflict) {
+		t.Fatalf("ожидалась ErrConflict, получено %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStore_GetNameNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("Ошибка %s, создание мока", err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT "user_id", "name", "phone_number" FROM "users" WHERE (("user_id" = $1) AND ("erased_at" IS NULL)) LIMIT $2`)).
+		WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "name", "phone_number"}))
+
+	store := Store{
+		db:  &DBMock{db: db},
+		log: logrus.StandardLogger(),
+		CloseFn: func(ctx context.Context) error {
+			return db.Close()
+		},
+	}
+
+	defer store.CloseFn(context.Background())
+
+	if _, err := store.GetName(context.Background(), 1); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("ожидалась ErrNotFound, получено %v", err)
+	}
+}
+
+func TestStore_FindFriendsDeletedCursor(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Errorf("Ошибка %s, создание мока", err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT 1 FROM "relations" INNER JOIN "users" ON ("relations"."relation_user_id" = "users"."user_id") WHERE (("relations"."relation_id" = $1) AND ("relations"."user_id" = $2))`)).
+		WithArgs(7, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"?column?"}))
+
+	store := Store{
+		db:  &DBMock{db: db},
+		log: logrus.StandardLogger(),
+		CloseFn: func(ctx context.Context) error {
+			return db.Close()
+		},
+	}
+
+	defer store.CloseFn(context.Background())
+
+	_, err = store.FindFriends(context.Background(), 1, FriendsQuery{Sort: FriendsSortName, Cursor: 7, Limit: 10})
+	if !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("ожидалась ErrInvalidCursor, получено %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDBError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{name: "unique", err: &pgconn.PgError{Code: pgUniqueViolation}, want: ErrConflict},
+		{name: "foreign key", err: &pgconn.PgError{Code: pgForeignKeyViolation}, want: ErrInvalid},
+		{name: "too long", err: &pgconn.PgError{Code: pgStringDataRightTruncation}, want: ErrInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := dbError("failed", tt.err); !errors.Is(err, tt.want) {
+				t.Fatalf("ожидалась %v, получено %v", tt.want, err)
+			}
+		})
+	}
+
+	cause := errors.New("connection reset")
+	err := dbError("failed", cause)
+	if !errors.Is(err, cause) || errors.Is(err, ErrConflict) || errors.Is(err, ErrInvalid) {
+		t.Fatalf("прочие ошибки должны оборачиваться как есть, получено %v", err)
+	}
+}
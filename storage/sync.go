@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/doug-martin/goqu/v9"
+)
+
+// DeleteRelation удаляет связь пользователя с relationUserID, ErrNotFound если связи не было
+func (s *Store) DeleteRelation(ctx context.Context, uid, relationUserID int) error {
+	removed, err := s.DeleteRelations(ctx, uid, []int{relationUserID})
+	if err != nil {
+		return err
+	}
+	if removed == 0 {
+		return fmt.Errorf("relation %d -> %d: %w", uid, relationUserID, ErrNotFound)
+	}
+
+	return nil
+}
+
+// DeleteRelations удаляет связи пользователя с указанными пользователями,
+// возвращает количество удаленных связей
+func (s *Store) DeleteRelations(ctx context.Context, uid int, relationUserIDs []int) (int, error) {
+	deleteSQL, _, err := dialect.
+		Delete("relations").
+		Where(
+			goqu.C("user_id").Eq(uid),
+			goqu.L(`"relation_user_id" = ANY($1)`),
+		).
+		ToSQL()
+	if err != nil {
+		return 0, fmt.Errorf("failed to build a query delete relations: %w", err)
+	}
+
+	affected, err := s.Exec(ctx, deleteSQL, relationUserIDs)
+	if err != nil {
+		return 0, dbError("failed to execute a query delete relations", err)
+	}
+
+	return int(affected), nil
+}
+
+// DeleteRelationsExcept удаляет все связи пользователя, кроме связей с keep,
+// возвращает количество удаленных связей
+func (s *Store) DeleteRelationsExcept(ctx context.Context, uid int, keep []int) (int, error) {
+	deleteSQL, _, err := dialect.
+		Delete("relations").
+		Where(
+			goqu.C("user_id").Eq(uid),
+			goqu.L(`"relation_user_id" <> ALL($1)`),
+		).
+		ToSQL()
+	if err != nil {
+		return 0, fmt.Errorf("failed to build a query delete relations: %w", err)
+	}
+
+	// nil-слайс передается как NULL, с которым ALL не удалит ни одной строки
+	if keep == nil {
+		keep = []int{}
+	}
+
+	affected, err := s.Exec(ctx, deleteSQL, keep)
+	if err != nil {
+		return 0, dbError("failed to execute a query delete relations", err)
+	}
+
+	return int(affected), nil
+}
+
+// DeletePendingContacts удаляет отложенные контакты пользователя с указанными номерами
+func (s *Store) DeletePendingContacts(ctx context.Context, uid int, numbers []string) error {
+	deleteSQL, _, err := dialect.
+		Delete("pending_contacts").
+		Where(
+			goqu.C("user_id").Eq(uid),
+			goqu.L(`"phone_index" = ANY($1)`),
+		).
+		ToSQL()
+	if err != nil {
+		return fmt.Errorf("failed to build a query delete pending contacts: %w", err)
+	}
+
+	if _, err := s.Exec(ctx, deleteSQL, s.phoneIndexes(numbers)); err != nil {
+		return dbError("failed to execute a query delete pending contacts", err)
+	}
+
+	return nil
+}
+
+// DeletePendingContactsExcept удаляет все отложенные контакты пользователя, кроме номеров keep
+func (s *Store) DeletePendingContactsExcept(ctx context.Context, uid int, keep []string) error {
+	deleteSQL, _, err := dialect.
+		Delete("pending_contacts").
+		Where(
+			goqu.C("user_id").Eq(uid),
+			goqu.L(`"phone_index" <> ALL($1)`),
+		).
+		ToSQL()
+	if err != nil {
+		return fmt.Errorf("failed to build a query delete pending contacts: %w", err)
+	}
+
+	if _, err := s.Exec(ctx, deleteSQL, s.phoneIndexes(keep)); err != nil {
+		return dbError("failed to execute a query delete pending contacts", err)
+	}
+
+	return nil
+}
+
+// NextSyncVersion увеличивает версию адресной книги пользователя после полной синхронизации
+func (s *Store) NextSyncVersion(ctx context.Context, uid int) (int64, error) {
+	upsertSQL, args, err := dialect.
+		Insert("contact_sync").
+		Prepared(true).
+		Rows(goqu.Record{"user_id": uid, "version": 1}).
+		OnConflict(goqu.DoUpdate("user_id", goqu.Record{
+			"version":    goqu.L(`"contact_sync"."version" + 1`),
+			"updated_at": goqu.L("now()"),
+		})).
+		Returning("version").
+		ToSQL()
+	if err != nil {
+		return 0, fmt.Errorf("failed to build a query sync version: %w", err)
+	}
+
+	version, _, err := s.querySyncVersion(ctx, upsertSQL, args...)
+	return version, err
+}
+
+// AdvanceSyncVersion увеличивает версию адресной книги, только если текущая версия равна expected,
+// false означает, что клиент работал с устаревшей версией
+func (s *Store) AdvanceSyncVersion(ctx context.Context, uid int, expected int64) (int64, bool, error) {
+	updateSQL, args, err := dialect.
+		Update("contact_sync").
+		Prepared(true).
+		Set(goqu.Record{
+			"version":    goqu.L(`"version" + 1`),
+			"updated_at": goqu.L("now()"),
+		}).
+		Where(
+			goqu.C("user_id").Eq(uid),
+			goqu.C("version").Eq(expected),
+		).
+		Returning("version").
+		ToSQL()
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to build a query sync version: %w", err)
+	}
+
+	return s.querySyncVersion(ctx, updateSQL, args...)
+}
+
+func (s *Store) querySyncVersion(ctx context.Context, sql string, args ...interface{}) (int64, bool, error) {
+	rows, err := s.Query(ctx, sql, args...)
+	if err != nil {
+		return 0, false, dbError("failed to execute a query sync version", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return 0, false, dbError("failed to read sync version", err)
+		}
+		return 0, false, nil
+	}
+
+	var version int64
+	if err := rows.Scan(&version); err != nil {
+		return 0, false, fmt.Errorf("failed to read sync version from database: %w", err)
+	}
+
+	return version, true, nil
+}
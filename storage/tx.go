@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sirupsen/logrus"
+)
+
+type txKey struct{}
+
+// TxManager выполняет функции в транзакции, транзакция передается через контекст,
+// методы Store, получившие такой контекст, работают внутри нее
+type TxManager struct {
+	db  DB
+	log logrus.FieldLogger
+}
+
+func NewTxManager(db DB, log logrus.FieldLogger) *TxManager {
+	return &TxManager{
+		db:  db,
+		log: log,
+	}
+}
+
+// WithinTx открывает транзакцию с опциями opts и выполняет в ней fn.
+// Если fn вернула ошибку или запаниковала - транзакция откатывается, иначе фиксируется.
+// Вложенный вызов открывает savepoint в уже начатой транзакции, opts при этом не применяются.
+func (m *TxManager) WithinTx(ctx context.Context, opts pgx.TxOptions, fn func(ctx context.Context) error) (err error) {
+	var tx pgx.Tx
+	if parent, ok := txFromContext(ctx); ok {
+		tx, err = parent.Begin(ctx)
+	} else {
+		tx, err = m.db.BeginTx(ctx, opts)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			m.rollback(ctx, tx)
+			panic(p)
+		}
+
+		if err != nil {
+			m.rollback(ctx, tx)
+			return
+		}
+
+		if err = tx.Commit(ctx); err != nil {
+			err = fmt.Errorf("failed to commit transaction: %w", err)
+		}
+	}()
+
+	return fn(context.WithValue(ctx, txKey{}, tx))
+}
+
+func (m *TxManager) rollback(ctx context.Context, tx pgx.Tx) {
+	if err := tx.Rollback(ctx); err != nil {
+		m.log.WithError(err).Error("Failed to rollback transaction")
+	}
+}
+
+func txFromContext(ctx context.Context) (pgx.Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(pgx.Tx)
+	return tx, ok
+}
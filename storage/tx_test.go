@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sirupsen/logrus"
+)
+
+type txMock struct {
+	pgx.Tx
+
+	parent     *txMock
+	committed  bool
+	rolledBack bool
+}
+
+func (t *txMock) Begin(ctx context.Context) (pgx.Tx, error) {
+	return &txMock{parent: t}, nil
+}
+
+func (t *txMock) Commit(ctx context.Context) error {
+	t.committed = true
+	return nil
+}
+
+func (t *txMock) Rollback(ctx context.Context) error {
+	t.rolledBack = true
+	return nil
+}
+
+type txDBMock struct {
+	DBMock
+
+	opts pgx.TxOptions
+	tx   *txMock
+}
+
+func (d *txDBMock) BeginTx(ctx context.Context, opts pgx.TxOptions) (pgx.Tx, error) {
+	d.opts = opts
+	d.tx = &txMock{}
+	return d.tx, nil
+}
+
+func TestTxManager_WithinTx(t *testing.T) {
+	db := &txDBMock{}
+	m := NewTxManager(db, logrus.StandardLogger())
+
+	opts := pgx.TxOptions{IsoLevel: pgx.Serializable}
+	err := m.WithinTx(context.Background(), opts, func(ctx context.Context) error {
+		if _, ok := txFromContext(ctx); !ok {
+			t.Fatal("в контексте должна быть транзакция")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if db.opts != opts {
+		t.Fatal("опции транзакции не переданы")
+	}
+	if !db.tx.committed || db.tx.rolledBack {
+		t.Fatal("транзакция должна была зафиксироваться")
+	}
+}
+
+func TestTxManager_WithinTxRollback(t *testing.T) {
+	db := &txDBMock{}
+	m := NewTxManager(db, logrus.StandardLogger())
+
+	errFn := errors.New("ошибка")
+	err := m.WithinTx(context.Background(), pgx.TxOptions{}, func(ctx context.Context) error {
+		return errFn
+	})
+	if !errors.Is(err, errFn) {
+		t.Fatalf("должна была вернуться ошибка функции, получено %v", err)
+	}
+
+	if db.tx.committed || !db.tx.rolledBack {
+		t.Fatal("транзакция должна была откатиться")
+	}
+}
+
+func TestTxManager_WithinTxPanic(t *testing.T) {
+	db := &txDBMock{}
+	m := NewTxManager(db, logrus.StandardLogger())
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("паника должна была пробрасываться дальше")
+			}
+		}()
+
+		_ = m.WithinTx(context.Background(), pgx.TxOptions{}, func(ctx context.Context) error {
+			panic("паника")
+		})
+	}()
+
+	if db.tx.committed || !db.tx.rolledBack {
+		t.Fatal("транзакция должна была откатиться")
+	}
+}
+
+func TestTxManager_WithinTxNested(t *testing.T) {
+	db := &txDBMock{}
+	m := NewTxManager(db, logrus.StandardLogger())
+
+	var savepoint *txMock
+	err := m.WithinTx(context.Background(), pgx.TxOptions{}, func(ctx context.Context) error {
+		_ = m.WithinTx(ctx, pgx.TxOptions{}, func(ctx context.Context) error {
+			tx, _ := txFromContext(ctx)
+			savepoint = tx.(*txMock)
+			return errors.New("ошибка")
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if savepoint.parent != db.tx {
+		t.Fatal("вложенный вызов должен открывать savepoint")
+	}
+	if !savepoint.rolledBack {
+		t.Fatal("savepoint должен был откатиться")
+	}
+	if !db.tx.committed {
+		t.Fatal("внешняя транзакция должна была зафиксироваться")
+	}
+}
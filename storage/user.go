@@ -0,0 +1,205 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"integ/entities"
+
+	"github.com/doug-martin/goqu/v9"
+)
+
+func (s *Store) CreateUser(ctx context.Context, user *entities.User) (*entities.User, error) {
+	sealed, err := s.sealPhone(user.PhoneNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	insertSQL, args, err := dialect.
+		Insert("users").
+		Prepared(true).
+		Rows(goqu.Record{
+			"name":         user.Name,
+			"phone_number": sealed,
+			"phone_index":  s.phoneIndex(user.PhoneNumber),
+			"phone_hash":   s.phoneHash(user.PhoneHash),
+		}).
+		Returning(userCols...).
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build a query insert user: %w", err)
+	}
+
+	return s.queryUser(ctx, insertSQL, args...)
+}
+
+func (s *Store) GetUser(ctx context.Context, uid int) (*entities.User, error) {
+	selectSQL, args, err := userTable.
+		Select(userCols...).
+		Where(
+			goqu.C("user_id").Eq(uid),
+			goqu.C("erased_at").IsNull(),
+		).
+		Limit(uint(1)).
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build a query user: %w", err)
+	}
+
+	return s.queryUser(ctx, selectSQL, args...)
+}
+
+func (s *Store) UpdateUser(ctx context.Context, uid int, update entities.UserUpdate) (*entities.User, error) {
+	if update.IsEmpty() {
+		return s.GetUser(ctx, uid)
+	}
+
+	record := goqu.Record{}
+	if update.Name != nil {
+		record["name"] = *update.Name
+	}
+	if update.PhoneNumber != nil {
+		sealed, err := s.sealPhone(*update.PhoneNumber)
+		if err != nil {
+			return nil, err
+		}
+		record["phone_number"] = sealed
+		record["phone_index"] = s.phoneIndex(*update.PhoneNumber)
+		// новый номер нужно подтвердить заново
+		record["phone_verified_at"] = nil
+	}
+	if update.PhoneHash != nil {
+		record["phone_hash"] = s.phoneHash(*update.PhoneHash)
+	}
+
+	updateSQL, args, err := dialect.
+		Update("users").
+		Prepared(true).
+		Set(record).
+		Where(
+			goqu.C("user_id").Eq(uid),
+			goqu.C("erased_at").IsNull(),
+		).
+		Returning(userCols...).
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build a query update user: %w", err)
+	}
+
+	return s.queryUser(ctx, updateSQL, args...)
+}
+
+// DeleteUser удаляет пользователя вместе со связями и данными, должен выполняться в транзакции
+func (s *Store) DeleteUser(ctx context.Context, uid int) error {
+	deleteSQL, args, err := dialect.
+		Delete("users").
+		Prepared(true).
+		Where(goqu.C("user_id").Eq(uid)).
+		ToSQL()
+	if err != nil {
+		return fmt.Errorf("failed to build a query delete user: %w", err)
+	}
+
+	affected, err := s.Exec(ctx, deleteSQL, args...)
+	if err != nil {
+		return dbError("failed to execute a query delete user", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("user %d: %w", uid, ErrNotFound)
+	}
+
+	return s.deleteUserData(ctx, uid)
+}
+
+// EraseUser удаляет данные пользователя и все связи с ним, оставляя обезличенную запись,
+// чтобы идентификатор не был выдан повторно. Должен выполняться в транзакции
+func (s *Store) EraseUser(ctx context.Context, uid int) error {
+	eraseSQL, args, err := dialect.
+		Update("users").
+		Prepared(true).
+		Set(goqu.Record{
+			"name":              "",
+			"phone_number":      "",
+			"phone_index":       nil,
+			"phone_hash":        nil,
+			"phone_verified_at": nil,
+			"discoverability":   string(entities.DiscoverableNobody),
+			"erased_at":         goqu.L("now()"),
+		}).
+		Where(
+			goqu.C("user_id").Eq(uid),
+			goqu.C("erased_at").IsNull(),
+		).
+		ToSQL()
+	if err != nil {
+		return fmt.Errorf("failed to build a query erase user: %w", err)
+	}
+
+	affected, err := s.Exec(ctx, eraseSQL, args...)
+	if err != nil {
+		return dbError("failed to execute a query erase user", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("user %d: %w", uid, ErrNotFound)
+	}
+
+	return s.deleteUserData(ctx, uid)
+}
+
+// deleteUserData удаляет связи пользователя в обе стороны и все его данные, кроме строки users
+func (s *Store) deleteUserData(ctx context.Context, uid int) error {
+	deletes := []*goqu.DeleteDataset{
+		dialect.Delete("relations").Where(goqu.Or(
+			goqu.C("user_id").Eq(uid),
+			goqu.C("relation_user_id").Eq(uid),
+		)),
+		dialect.Delete("blocks").Where(goqu.Or(
+			goqu.C("user_id").Eq(uid),
+			goqu.C("blocked_user_id").Eq(uid),
+		)),
+		dialect.Delete("pending_contacts").Where(goqu.C("user_id").Eq(uid)),
+		dialect.Delete("phone_verifications").Where(goqu.C("user_id").Eq(uid)),
+		dialect.Delete("contact_sync").Where(goqu.C("user_id").Eq(uid)),
+	}
+	for _, del := range deletes {
+		deleteSQL, args, err := del.Prepared(true).ToSQL()
+		if err != nil {
+			return fmt.Errorf("failed to build a query delete user data: %w", err)
+		}
+
+		if _, err := s.Exec(ctx, deleteSQL, args...); err != nil {
+			return dbError("failed to execute a query delete user data", err)
+		}
+	}
+
+	return nil
+}
+
+// queryUser выполняет запрос, возвращающий не больше одного пользователя,
+// если строк нет - возвращает ErrNotFound
+func (s *Store) queryUser(ctx context.Context, sql string, args ...interface{}) (*entities.User, error) {
+	rows, err := s.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, dbError("failed to execute a query user", err)
+	}
+	defer rows.Close()
+
+	var user *entities.User
+	for rows.Next() {
+		userRaw, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read user from database: %w", err)
+		}
+		user, err = s.buildUser(userRaw)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError("failed to read user", err)
+	}
+	if user == nil {
+		return nil, fmt.Errorf("user: %w", ErrNotFound)
+	}
+
+	return user, nil
+}
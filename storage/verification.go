@@ -0,0 +1,189 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"integ/entities"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+)
+
+var verificationCols = []interface{}{
+	"user_id",
+	"phone_number",
+	"code_hash",
+	"salt",
+	"attempts",
+	"expires_at",
+	"created_at",
+}
+
+// SavePhoneVerification сохраняет новый код подтверждения номера, предыдущий код
+// пользователя заменяется. Счетчик попыток сбрасывается, только если предыдущий код истек,
+// чтобы повторная отправка не давала новых попыток подбора
+func (s *Store) SavePhoneVerification(ctx context.Context, verification *entities.PhoneVerification) error {
+	sealed, err := s.sealPhone(verification.PhoneNumber)
+	if err != nil {
+		return err
+	}
+
+	keepAttempts := goqu.L(`CASE WHEN "phone_verifications"."expires_at" > now() THEN "phone_verifications"."attempts" ELSE 0 END`)
+
+	insertSQL, args, err := dialect.
+		Insert("phone_verifications").
+		Prepared(true).
+		Rows(goqu.Record{
+			"user_id":      verification.UserID,
+			"phone_number": sealed,
+			"code_hash":    verification.CodeHash,
+			"salt":         verification.Salt,
+			"expires_at":   verification.ExpiresAt,
+		}).
+		OnConflict(goqu.DoUpdate("user_id", goqu.Record{
+			"phone_number": goqu.I("excluded.phone_number"),
+			"code_hash":    goqu.I("excluded.code_hash"),
+			"salt":         goqu.I("excluded.salt"),
+			"expires_at":   goqu.I("excluded.expires_at"),
+			"attempts":     keepAttempts,
+			"created_at":   goqu.L("now()"),
+		})).
+		ToSQL()
+	if err != nil {
+		return fmt.Errorf("failed to build a query save phone verification: %w", err)
+	}
+
+	if _, err := s.Exec(ctx, insertSQL, args...); err != nil {
+		return dbError("failed to execute a query save phone verification", err)
+	}
+
+	return nil
+}
+
+// GetPhoneVerification возвращает текущий код пользователя и блокирует строку до конца транзакции,
+// ErrNotFound если код не запрашивался
+func (s *Store) GetPhoneVerification(ctx context.Context, uid int) (*entities.PhoneVerification, error) {
+	selectSQL, args, err := dialect.
+		From("phone_verifications").
+		Prepared(true).
+		Select(verificationCols...).
+		Where(goqu.C("user_id").Eq(uid)).
+		ForUpdate(exp.Wait).
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build a query phone verification: %w", err)
+	}
+
+	rows, err := s.Query(ctx, selectSQL, args...)
+	if err != nil {
+		return nil, dbError("failed to execute a query phone verification", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, dbError("failed to read phone verification", err)
+		}
+		return nil, fmt.Errorf("phone verification of user %d: %w", uid, ErrNotFound)
+	}
+
+	var verification entities.PhoneVerification
+	err = rows.Scan(
+		&verification.UserID,
+		&verification.PhoneNumber,
+		&verification.CodeHash,
+		&verification.Salt,
+		&verification.Attempts,
+		&verification.ExpiresAt,
+		&verification.CreatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read phone verification from database: %w", err)
+	}
+
+	verification.PhoneNumber, err = s.openPhone(verification.PhoneNumber)
+	if err != nil {
+		return nil, fmt.Errorf("phone verification of user %d: %w", uid, err)
+	}
+
+	return &verification, nil
+}
+
+// IncrementVerificationAttempts учитывает неудачную попытку ввода кода
+func (s *Store) IncrementVerificationAttempts(ctx context.Context, uid int) error {
+	updateSQL, args, err := dialect.
+		Update("phone_verifications").
+		Prepared(true).
+		Set(goqu.Record{"attempts": goqu.L(`"attempts" + 1`)}).
+		Where(goqu.C("user_id").Eq(uid)).
+		ToSQL()
+	if err != nil {
+		return fmt.Errorf("failed to build a query verification attempts: %w", err)
+	}
+
+	if _, err := s.Exec(ctx, updateSQL, args...); err != nil {
+		return dbError("failed to execute a query verification attempts", err)
+	}
+
+	return nil
+}
+
+func (s *Store) DeletePhoneVerification(ctx context.Context, uid int) error {
+	deleteSQL, args, err := dialect.
+		Delete("phone_verifications").
+		Prepared(true).
+		Where(goqu.C("user_id").Eq(uid)).
+		ToSQL()
+	if err != nil {
+		return fmt.Errorf("failed to build a query delete phone verification: %w", err)
+	}
+
+	if _, err := s.Exec(ctx, deleteSQL, args...); err != nil {
+		return dbError("failed to execute a query delete phone verification", err)
+	}
+
+	return nil
+}
+
+// MarkPhoneVerified отмечает номер пользователя подтвержденным, если он не менялся,
+// у остальных пользователей с тем же номером подтверждение снимается
+func (s *Store) MarkPhoneVerified(ctx context.Context, uid int, number string) (*entities.User, error) {
+	verifySQL, args, err := dialect.
+		Update("users").
+		Prepared(true).
+		Set(goqu.Record{"phone_verified_at": goqu.L("now()")}).
+		Where(
+			goqu.C("user_id").Eq(uid),
+			goqu.C("phone_index").Eq(s.phoneIndex(number)),
+		).
+		Returning(userCols...).
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build a query verify phone: %w", err)
+	}
+
+	user, err := s.queryUser(ctx, verifySQL, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	unverifySQL, args, err := dialect.
+		Update("users").
+		Prepared(true).
+		Set(goqu.Record{"phone_verified_at": nil}).
+		Where(
+			goqu.C("phone_index").Eq(s.phoneIndex(number)),
+			goqu.C("user_id").Neq(uid),
+			goqu.C("phone_verified_at").IsNotNull(),
+		).
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build a query unverify phone: %w", err)
+	}
+
+	if _, err := s.Exec(ctx, unverifySQL, args...); err != nil {
+		return nil, dbError("failed to execute a query unverify phone", err)
+	}
+
+	return user, nil
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"fmt"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/sirupsen/logrus"
 	"integ/storage"
 	"integ/storage/migration"
@@ -17,12 +18,12 @@ type testCtx struct {
 	store *storage.Store
 }
 
-func prepareTestContext(t *testing.T) *testCtx {
+func prepareTestContext(t *testing.T, opts ...func(*storage.Store)) *testCtx {
 	t.Helper()
 
 	testCtx := &testCtx{t: t}
 
-	store, cleanupFunc := prepareTestStore(t)
+	store, cleanupFunc := prepareTestStore(t, opts...)
 	t.Cleanup(cleanupFunc)
 
 	testCtx.store = store
@@ -30,7 +31,7 @@ func prepareTestContext(t *testing.T) *testCtx {
 	return testCtx
 }
 
-func prepareTestStore(t *testing.T) (*storage.Store, func()) {
+func prepareTestStore(t *testing.T, opts ...func(*storage.Store)) (*storage.Store, func()) {
 	t.Helper()
 
 	connConfig, err := pgx.ParseConfig(GetPostgresURL())
@@ -48,15 +49,18 @@ func prepareTestStore(t *testing.T) (*storage.Store, func()) {
 	if _, err = dbConn.Exec(context.Background(), "CREATE SCHEMA "+schemaName); err != nil {
 		t.Fatalf("Failed to create test DB schema: %v", err)
 	}
-	connConfig.RuntimeParams["search_path"] = schemaName
-
 	connString := fmt.Sprintf("%s search_path=%s", connConfig.ConnString(), schemaName)
 
 	if err := evolution(connString); err != nil {
 		t.Fatalf("ошибка при миграции бд %s", err)
 	}
 
-	store, err := storage.New(context.Background(), connConfig, logrus.StandardLogger())
+	poolConfig, err := pgxpool.ParseConfig(connString)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := storage.New(context.Background(), poolConfig, logrus.StandardLogger(), opts...)
 	if err != nil {
 		_ = dbConn.Close(context.Background())
 		t.Fatalf("ошибка создания хранилища, причина %v", err)
@@ -64,6 +68,7 @@ func prepareTestStore(t *testing.T) (*storage.Store, func()) {
 
 	return store, func() {
 		defer dbConn.Close(context.Background())
+		_ = store.CloseFn(context.Background())
 
 		if _, err := dbConn.Exec(context.Background(), `DROP SCHEMA `+schemaName+` CASCADE`); err != nil {
 			t.Errorf("drop schema %q failed: %v", schemaName, err)
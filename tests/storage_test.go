@@ -2,21 +2,29 @@ package tests
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"integ/entities"
+	"integ/keyring"
+	"integ/phone"
+	"integ/storage"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	_ "github.com/lib/pq"
 )
 
 func TestStore_FindUserByPhone(t *testing.T) {
 	testCtx := prepareTestContext(t)
 
-	_, err := testCtx.store.Exec(context.Background(), `insert into users (name, phone_number) values ('Пользователь1', '+7983');`)
-	_, err = testCtx.store.Exec(context.Background(), `insert into users (name, phone_number) values ('Пользователь2', '+7984');`)
-	_, err = testCtx.store.Exec(context.Background(), `insert into users (name, phone_number) values ('Пользователь3', '+7985');`)
-	_, err = testCtx.store.Exec(context.Background(), `insert into users (name, phone_number) values ('Пользователь4', '+7986');`)
-	_, err = testCtx.store.Exec(context.Background(), `insert into users (name, phone_number) values ('Пользователь5', '+7987');`)
-	_, err = testCtx.store.Exec(context.Background(), `insert into users (name, phone_number) values ('Пользователь2', '+7988');`)
+	_, err := testCtx.store.Exec(context.Background(), `insert into users (name, phone_number, phone_index, phone_verified_at) values ('Пользователь1', '+7983', '+7983', now());`)
+	_, err = testCtx.store.Exec(context.Background(), `insert into users (name, phone_number, phone_index) values ('Пользователь2', '+7984', '+7984');`)
+	_, err = testCtx.store.Exec(context.Background(), `insert into users (name, phone_number, phone_index) values ('Пользователь3', '+7985', '+7985');`)
+	_, err = testCtx.store.Exec(context.Background(), `insert into users (name, phone_number, phone_index) values ('Пользователь4', '+7986', '+7986');`)
+	_, err = testCtx.store.Exec(context.Background(), `insert into users (name, phone_number, phone_index) values ('Пользователь5', '+7987', '+7987');`)
+	_, err = testCtx.store.Exec(context.Background(), `insert into users (name, phone_number, phone_index) values ('Пользователь2', '+7988', '+7988');`)
 
 	user, err := testCtx.store.FindUserByPhone(context.Background(), "+7983")
 	if err != nil {
@@ -27,6 +35,10 @@ func TestStore_FindUserByPhone(t *testing.T) {
 		t.Fatal("должен был вернуться первый пользователь")
 	}
 
+	if _, err := testCtx.store.FindUserByPhone(context.Background(), "+7984"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("неподтвержденный номер не должен находиться, получено %v", err)
+	}
+
 }
 
 func TestStore_FindFriends(t *testing.T) {
@@ -44,11 +56,13 @@ func TestStore_FindFriends(t *testing.T) {
 	// _, err = testCtx.store.Exec(context.Background(), `insert into relations (user_id, relation_user_id) values (1, 3);`)
 	// _, err = testCtx.store.Exec(context.Background(), `insert into relations (user_id, relation_user_id) values (1, 4);`)
 
-	err = testCtx.store.SaveRelation(context.Background(), &entities.Relation{UserID: 1, RelationUserID: 2})
-	err = testCtx.store.SaveRelation(context.Background(), &entities.Relation{UserID: 1, RelationUserID: 3})
-	err = testCtx.store.SaveRelation(context.Background(), &entities.Relation{UserID: 1, RelationUserID: 4})
+	_, err = testCtx.store.SaveRelations(context.Background(), entities.RelationList{
+		{UserID: 1, RelationUserID: 2},
+		{UserID: 1, RelationUserID: 3},
+		{UserID: 1, RelationUserID: 4},
+	})
 
-	rel, err := testCtx.store.FindFriends(context.Background(), 1)
+	rel, err := testCtx.store.FindFriends(context.Background(), 1, storage.FriendsQuery{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -93,3 +107,988 @@ func TestStore_GetName(t *testing.T) {
 // 	err = testCtx.store.SaveRelation(context.Background(), &entities.Relation{1, 2})
 
 // }
+
+func TestStore_UserCRUD(t *testing.T) {
+	testCtx := prepareTestContext(t)
+	ctx := context.Background()
+
+	user, err := testCtx.store.CreateUser(ctx, &entities.User{Name: "Пользователь1", PhoneNumber: "+7983"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.UserID == 0 {
+		t.Fatal("должен был вернуться идентификатор пользователя")
+	}
+
+	name := "Пользователь2"
+	updated, err := testCtx.store.UpdateUser(ctx, user.UserID, entities.UserUpdate{Name: &name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Name != name || updated.PhoneNumber != "+7983" {
+		t.Fatal("пользователь обновлен неверно")
+	}
+
+	other, err := testCtx.store.CreateUser(ctx, &entities.User{Name: "Пользователь3", PhoneNumber: "+7984"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testCtx.store.SaveRelations(ctx, entities.RelationList{
+		{UserID: user.UserID, RelationUserID: other.UserID},
+		{UserID: other.UserID, RelationUserID: user.UserID},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := testCtx.store.DeleteUser(ctx, user.UserID); err != nil {
+		t.Fatal(err)
+	}
+
+	// выгрузка читает relations без соединения с users, поэтому видит и осиротевшие связи
+	export, err := testCtx.store.ExportUser(ctx, other.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(export.Relations) != 0 || len(export.IncomingRelations) != 0 {
+		t.Fatal("связи удаленного пользователя должны удаляться вместе с ним")
+	}
+
+	if err := testCtx.store.DeleteUser(ctx, user.UserID); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("повторное удаление должно вернуть ErrNotFound, получено %v", err)
+	}
+
+	if _, err := testCtx.store.GetUser(ctx, user.UserID); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("удаленный пользователь не должен находиться, получено %v", err)
+	}
+
+	if _, err := testCtx.store.GetName(ctx, user.UserID); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("имя удаленного пользователя не должно находиться, получено %v", err)
+	}
+}
+
+func TestStore_FindUsersByPhones(t *testing.T) {
+	testCtx := prepareTestContext(t)
+	ctx := context.Background()
+
+	for _, u := range []entities.User{
+		{Name: "Пользователь1", PhoneNumber: "+79830000001"},
+		{Name: "Пользователь2", PhoneNumber: "+79830000002"},
+		{Name: "Пользователь3", PhoneNumber: "+79830000003"},
+	} {
+		created, err := testCtx.store.CreateUser(ctx, &u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := testCtx.store.MarkPhoneVerified(ctx, created.UserID, created.PhoneNumber); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	users, err := testCtx.store.FindUsersByPhones(ctx, []string{"+79830000001", "+79830000003", "+79830000009"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(users) != 2 {
+		t.Fatalf("должно было найтись 2 пользователя, найдено %d", len(users))
+	}
+	if users["+79830000003"] == nil || users["+79830000003"].Name != "Пользователь3" {
+		t.Fatal("не найден третий пользователь")
+	}
+}
+
+func TestStore_FindMutualAndCommonFriends(t *testing.T) {
+	testCtx := prepareTestContext(t)
+	ctx := context.Background()
+
+	for _, phone := range []string{"+7983", "+7984", "+7985", "+7986"} {
+		if _, err := testCtx.store.CreateUser(ctx, &entities.User{Name: "Пользователь", PhoneNumber: phone}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 1 <-> 2, 1 -> 3, 2 -> 3, 4 -> 1
+	_, err := testCtx.store.SaveRelations(ctx, entities.RelationList{
+		{UserID: 1, RelationUserID: 2},
+		{UserID: 2, RelationUserID: 1},
+		{UserID: 1, RelationUserID: 3},
+		{UserID: 2, RelationUserID: 3},
+		{UserID: 4, RelationUserID: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mutual, err := testCtx.store.FindMutualFriends(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mutual) != 1 || mutual[0].RelationUserID != 2 {
+		t.Fatal("взаимным другом должен быть только второй пользователь")
+	}
+
+	common, err := testCtx.store.FindCommonFriends(ctx, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(common) != 1 || common[0].RelationUserID != 3 {
+		t.Fatal("общим контактом должен быть только третий пользователь")
+	}
+
+	// скрытый или заблокированный пользователь неотличим от несуществующего
+	if _, err := testCtx.store.UpdatePrivacy(ctx, 2, entities.PrivacySettings{Discoverability: entities.DiscoverableNobody}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testCtx.store.FindCommonFriends(ctx, 1, 2); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("ожидалась ErrNotFound для скрытого пользователя, получено %v", err)
+	}
+	if _, err := testCtx.store.UpdatePrivacy(ctx, 2, entities.PrivacySettings{Discoverability: entities.DiscoverableEveryone}); err != nil {
+		t.Fatal(err)
+	}
+	if err := testCtx.store.BlockUser(ctx, 2, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testCtx.store.FindCommonFriends(ctx, 1, 2); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("ожидалась ErrNotFound для заблокировавшего пользователя, получено %v", err)
+	}
+	if _, err := testCtx.store.FindCommonFriends(ctx, 2, 1); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("ожидалась ErrNotFound для заблокированного пользователя, получено %v", err)
+	}
+}
+
+func TestStore_FindFollowers(t *testing.T) {
+	testCtx := prepareTestContext(t)
+	ctx := context.Background()
+
+	for _, u := range []entities.User{
+		{Name: "Пользователь1", PhoneNumber: "+7983"},
+		{Name: "Пользователь2", PhoneNumber: "+7984"},
+		{Name: "Пользователь3", PhoneNumber: "+7985"},
+	} {
+		if _, err := testCtx.store.CreateUser(ctx, &u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := testCtx.store.SaveRelations(ctx, entities.RelationList{
+		{UserID: 2, RelationUserID: 1},
+		{UserID: 3, RelationUserID: 1},
+		{UserID: 1, RelationUserID: 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	followers, err := testCtx.store.FindFollowers(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(followers) != 2 || followers[0].Name != "Пользователь2" || followers[1].Name != "Пользователь3" {
+		t.Fatal("вернулся неправильный список подписчиков")
+	}
+}
+
+func TestStore_FindSuggestions(t *testing.T) {
+	testCtx := prepareTestContext(t)
+	ctx := context.Background()
+
+	for _, phone := range []string{"+7983", "+7984", "+7985", "+7986", "+7987"} {
+		if _, err := testCtx.store.CreateUser(ctx, &entities.User{Name: "Пользователь", PhoneNumber: phone}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// у 1 в контактах 2 и 3, через них он знает 4 (дважды), 5 (однажды) и 3
+	_, err := testCtx.store.SaveRelations(ctx, entities.RelationList{
+		{UserID: 1, RelationUserID: 2},
+		{UserID: 1, RelationUserID: 3},
+		{UserID: 2, RelationUserID: 1},
+		{UserID: 2, RelationUserID: 3},
+		{UserID: 2, RelationUserID: 4},
+		{UserID: 3, RelationUserID: 4},
+		{UserID: 3, RelationUserID: 5},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	suggestions, err := testCtx.store.FindSuggestions(ctx, 1, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(suggestions) != 2 {
+		t.Fatalf("должно было вернуться 2 предложения, вернулось %d", len(suggestions))
+	}
+	if suggestions[0].UserID != 4 || suggestions[0].MutualCount != 2 {
+		t.Fatal("первым должен быть пользователь с двумя общими контактами")
+	}
+	if suggestions[1].UserID != 5 || suggestions[1].MutualCount != 1 {
+		t.Fatal("вторым должен быть пользователь с одним общим контактом")
+	}
+}
+
+func TestStore_FindFriendsPaging(t *testing.T) {
+	testCtx := prepareTestContext(t)
+	ctx := context.Background()
+
+	for _, u := range []entities.User{
+		{Name: "Пользователь", PhoneNumber: "+7983"},
+		{Name: "Борис", PhoneNumber: "+7984"},
+		{Name: "Анна", PhoneNumber: "+7985"},
+		{Name: "Вера", PhoneNumber: "+7986"},
+	} {
+		if _, err := testCtx.store.CreateUser(ctx, &u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := testCtx.store.SaveRelations(ctx, entities.RelationList{
+		{UserID: 1, RelationUserID: 2},
+		{UserID: 1, RelationUserID: 3},
+		{UserID: 1, RelationUserID: 4},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := testCtx.store.FindFriends(ctx, 1, storage.FriendsQuery{Sort: storage.FriendsSortName, Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first) != 2 || first[0].Name != "Анна" || first[1].Name != "Борис" {
+		t.Fatal("первая страница должна быть отсортирована по имени")
+	}
+
+	second, err := testCtx.store.FindFriends(ctx, 1, storage.FriendsQuery{
+		Sort:   storage.FriendsSortName,
+		Limit:  2,
+		Cursor: first[1].RelationID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(second) != 1 || second[0].RelationUserID != 4 {
+		t.Fatal("вторая страница должна продолжать первую")
+	}
+
+	if err := testCtx.store.DeleteRelation(ctx, 1, first[1].RelationUserID); err != nil {
+		t.Fatal(err)
+	}
+	_, err = testCtx.store.FindFriends(ctx, 1, storage.FriendsQuery{
+		Sort:   storage.FriendsSortName,
+		Limit:  2,
+		Cursor: first[1].RelationID,
+	})
+	if !errors.Is(err, storage.ErrInvalidCursor) {
+		t.Fatalf("курсор удаленной связи должен вернуть ErrInvalidCursor, получено %v", err)
+	}
+}
+
+func TestStore_SaveRelationsContactName(t *testing.T) {
+	testCtx := prepareTestContext(t)
+	ctx := context.Background()
+
+	for _, phone := range []string{"+7983", "+7984"} {
+		if _, err := testCtx.store.CreateUser(ctx, &entities.User{Name: "Пользователь", PhoneNumber: phone}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	created, err := testCtx.store.SaveRelations(ctx, entities.RelationList{{UserID: 1, RelationUserID: 2, ContactName: "Мама"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created != 1 {
+		t.Fatal("связь должна была добавиться")
+	}
+
+	created, err = testCtx.store.SaveRelations(ctx, entities.RelationList{{UserID: 1, RelationUserID: 2, ContactName: "Мамочка"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created != 0 {
+		t.Fatal("повторная загрузка не должна добавлять связь")
+	}
+
+	friends, err := testCtx.store.FindFriends(ctx, 1, storage.FriendsQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(friends) != 1 || friends[0].ContactName != "Мамочка" {
+		t.Fatal("имя контакта должно было обновиться")
+	}
+}
+
+func TestStore_MaterializePendingContacts(t *testing.T) {
+	testCtx := prepareTestContext(t)
+	ctx := context.Background()
+
+	uploader, err := testCtx.store.CreateUser(ctx, &entities.User{Name: "Пользователь1", PhoneNumber: "+79830000001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = testCtx.store.SavePendingContacts(ctx, []*entities.PendingContact{
+		{UserID: uploader.UserID, PhoneNumber: "+79830000002", ContactName: "Мама"},
+		{UserID: uploader.UserID, PhoneNumber: "+79830000003", ContactName: "Папа"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	joined, err := testCtx.store.CreateUser(ctx, &entities.User{Name: "Пользователь2", PhoneNumber: "+79830000002"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	relations, err := testCtx.store.MaterializePendingContacts(ctx, joined)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(relations) != 1 || relations[0].UserID != uploader.UserID || relations[0].ContactName != "Мама" {
+		t.Fatal("должна была появиться связь с загрузившим контакт")
+	}
+
+	relations, err = testCtx.store.MaterializePendingContacts(ctx, joined)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(relations) != 0 {
+		t.Fatal("отложенный контакт должен был удалиться")
+	}
+}
+
+func TestStore_MaterializePendingContactsHidden(t *testing.T) {
+	testCtx := prepareTestContext(t)
+	ctx := context.Background()
+
+	uploaders := make([]*entities.User, 0, 2)
+	for _, number := range []string{"+79830000001", "+79830000003"} {
+		uploader, err := testCtx.store.CreateUser(ctx, &entities.User{Name: "Пользователь", PhoneNumber: number})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = testCtx.store.SavePendingContacts(ctx, []*entities.PendingContact{
+			{UserID: uploader.UserID, PhoneNumber: "+79830000002", ContactName: "Мама"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		uploaders = append(uploaders, uploader)
+	}
+	visible, hidden := uploaders[0], uploaders[1]
+
+	joined, err := testCtx.store.CreateUser(ctx, &entities.User{Name: "Пользователь2", PhoneNumber: "+79830000002"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := testCtx.store.BlockUser(ctx, joined.UserID, hidden.UserID); err != nil {
+		t.Fatal(err)
+	}
+
+	relations, err := testCtx.store.MaterializePendingContacts(ctx, joined)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(relations) != 1 || relations[0].UserID != visible.UserID {
+		t.Fatalf("связь должна появиться только у загрузившего, которому виден пользователь, получено %+v", relations)
+	}
+
+	// отложенный контакт заблокированного не удаляется и становится связью после снятия блокировки
+	if err := testCtx.store.UnblockUser(ctx, joined.UserID, hidden.UserID); err != nil {
+		t.Fatal(err)
+	}
+	relations, err = testCtx.store.MaterializePendingContacts(ctx, joined)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(relations) != 1 || relations[0].UserID != hidden.UserID {
+		t.Fatalf("отложенный контакт скрытого пользователя должен был сохраниться, получено %+v", relations)
+	}
+}
+
+func TestStore_SyncVersionAndDeleteRelations(t *testing.T) {
+	testCtx := prepareTestContext(t)
+	ctx := context.Background()
+
+	for _, phone := range []string{"+7983", "+7984", "+7985", "+7986"} {
+		if _, err := testCtx.store.CreateUser(ctx, &entities.User{Name: "Пользователь", PhoneNumber: phone}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := testCtx.store.SaveRelations(ctx, entities.RelationList{
+		{UserID: 1, RelationUserID: 2},
+		{UserID: 1, RelationUserID: 3},
+		{UserID: 1, RelationUserID: 4},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := testCtx.store.DeleteRelationsExcept(ctx, 1, []int{2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 2 {
+		t.Fatalf("должно было удалиться 2 связи, удалено %d", removed)
+	}
+
+	removed, err = testCtx.store.DeleteRelations(ctx, 1, []int{2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 1 {
+		t.Fatalf("должна была удалиться 1 связь, удалено %d", removed)
+	}
+
+	version, err := testCtx.store.NextSyncVersion(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok, err := testCtx.store.AdvanceSyncVersion(ctx, 1, version+1); err != nil || ok {
+		t.Fatal("устаревший токен не должен приниматься")
+	}
+
+	next, ok, err := testCtx.store.AdvanceSyncVersion(ctx, 1, version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || next != version+1 {
+		t.Fatal("версия должна была увеличиться")
+	}
+}
+
+func TestStore_PhoneVerification(t *testing.T) {
+	testCtx := prepareTestContext(t)
+	ctx := context.Background()
+
+	first, err := testCtx.store.CreateUser(ctx, &entities.User{Name: "Пользователь1", PhoneNumber: "+79830000001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := testCtx.store.CreateUser(ctx, &entities.User{Name: "Пользователь2", PhoneNumber: "+79830000001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := testCtx.store.GetPhoneVerification(ctx, first.UserID); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("код еще не запрашивался, получено %v", err)
+	}
+
+	err = testCtx.store.SavePhoneVerification(ctx, &entities.PhoneVerification{
+		UserID:      first.UserID,
+		PhoneNumber: first.PhoneNumber,
+		CodeHash:    []byte("hash"),
+		Salt:        []byte("salt"),
+		ExpiresAt:   time.Now().Add(time.Minute),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := testCtx.store.IncrementVerificationAttempts(ctx, first.UserID); err != nil {
+		t.Fatal(err)
+	}
+
+	verification, err := testCtx.store.GetPhoneVerification(ctx, first.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verification.Attempts != 1 || string(verification.CodeHash) != "hash" || verification.PhoneNumber != first.PhoneNumber {
+		t.Fatal("код подтверждения сохранен неверно")
+	}
+
+	// повторная отправка кода не сбрасывает попытки, пока предыдущий код действует
+	err = testCtx.store.SavePhoneVerification(ctx, &entities.PhoneVerification{
+		UserID:      first.UserID,
+		PhoneNumber: first.PhoneNumber,
+		CodeHash:    []byte("hash2"),
+		Salt:        []byte("salt"),
+		ExpiresAt:   time.Now().Add(time.Minute),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verification, err = testCtx.store.GetPhoneVerification(ctx, first.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verification.Attempts != 1 || string(verification.CodeHash) != "hash2" {
+		t.Fatalf("ожидался новый код с сохраненным счетчиком попыток, попыток %d", verification.Attempts)
+	}
+
+	if _, err := testCtx.store.MarkPhoneVerified(ctx, second.UserID, second.PhoneNumber); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testCtx.store.MarkPhoneVerified(ctx, first.UserID, first.PhoneNumber); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := testCtx.store.FindUserByPhone(ctx, first.PhoneNumber)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.UserID != first.UserID {
+		t.Fatal("подтверждение номера должно сниматься с предыдущего владельца")
+	}
+
+	if _, err := testCtx.store.MarkPhoneVerified(ctx, first.UserID, "+79830000009"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("нельзя подтвердить чужой номер, получено %v", err)
+	}
+}
+
+func TestStore_PrivacyAndBlocks(t *testing.T) {
+	testCtx := prepareTestContext(t)
+	ctx := context.Background()
+
+	users := make([]*entities.User, 0, 3)
+	for _, phone := range []string{"+79830000001", "+79830000002", "+79830000003"} {
+		user, err := testCtx.store.CreateUser(ctx, &entities.User{Name: "Пользователь", PhoneNumber: phone})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := testCtx.store.MarkPhoneVerified(ctx, user.UserID, phone); err != nil {
+			t.Fatal(err)
+		}
+		users = append(users, user)
+	}
+	a, b, c := users[0], users[1], users[2]
+	numbers := []string{b.PhoneNumber, c.PhoneNumber}
+
+	if _, err := testCtx.store.UpdatePrivacy(ctx, b.UserID, entities.PrivacySettings{Discoverability: entities.DiscoverableNobody}); err != nil {
+		t.Fatal(err)
+	}
+	found, err := testCtx.store.FindDiscoverableUsersByPhones(ctx, a.UserID, numbers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[c.PhoneNumber] == nil {
+		t.Fatal("пользователь с discoverability=nobody не должен находиться")
+	}
+
+	if _, err := testCtx.store.UpdatePrivacy(ctx, b.UserID, entities.PrivacySettings{Discoverability: entities.DiscoverableContacts}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testCtx.store.SaveRelations(ctx, entities.RelationList{{UserID: b.UserID, RelationUserID: a.UserID}}); err != nil {
+		t.Fatal(err)
+	}
+	found, err = testCtx.store.FindDiscoverableUsersByPhones(ctx, a.UserID, numbers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 2 {
+		t.Fatal("пользователь с discoverability=contacts должен находиться теми, кто есть у него в контактах")
+	}
+
+	if _, err := testCtx.store.SaveRelations(ctx, entities.RelationList{
+		{UserID: a.UserID, RelationUserID: b.UserID},
+		{UserID: a.UserID, RelationUserID: c.UserID},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := testCtx.store.BlockUser(ctx, c.UserID, a.UserID); err != nil {
+		t.Fatal(err)
+	}
+
+	blocked, err := testCtx.store.FindBlockedUsers(ctx, c.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blocked) != 1 || blocked[0].BlockedUserID != a.UserID || blocked[0].CreatedAt.IsZero() {
+		t.Fatalf("ожидалась одна блокировка пользователя %d, получено %+v", a.UserID, blocked)
+	}
+
+	friends, err := testCtx.store.FindFriends(ctx, a.UserID, storage.FriendsQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(friends) != 1 || friends[0].RelationUserID != b.UserID {
+		t.Fatal("заблокировавший пользователь не должен быть в списке друзей")
+	}
+
+	followers, err := testCtx.store.FindFollowers(ctx, a.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(followers) != 1 || followers[0].UserID != b.UserID {
+		t.Fatal("в подписчиках должен остаться только второй пользователь")
+	}
+
+	if err := testCtx.store.UnblockUser(ctx, c.UserID, a.UserID); err != nil {
+		t.Fatal(err)
+	}
+	if err := testCtx.store.UnblockUser(ctx, c.UserID, a.UserID); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("повторное снятие блокировки должно вернуть ErrNotFound, получено %v", err)
+	}
+}
+
+func TestStore_FindDiscoverableUsersByHashes(t *testing.T) {
+	ring, err := keyring.New("k1", map[string]string{"k1": base64.StdEncoding.EncodeToString([]byte(strings.Repeat("a", keyring.KeySize)))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, err := phone.NewHasher("index")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCtx := prepareTestContext(t, storage.WithPhoneEncryption(ring, index))
+	ctx := context.Background()
+
+	hasher, err := phone.NewHasher("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uploader, err := testCtx.store.CreateUser(ctx, &entities.User{Name: "Загрузивший", PhoneNumber: "+79830000011"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	number := "+79830000012"
+	user, err := testCtx.store.CreateUser(ctx, &entities.User{Name: "Контакт", PhoneNumber: number, PhoneHash: hasher.Hash(number)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashes := []string{hasher.Hash(number), hasher.Hash("+79830000013")}
+
+	found, err := testCtx.store.FindDiscoverableUsersByHashes(ctx, uploader.UserID, hashes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 0 {
+		t.Fatal("пользователь с неподтвержденным номером не должен находиться по хешу")
+	}
+
+	if _, err := testCtx.store.MarkPhoneVerified(ctx, user.UserID, number); err != nil {
+		t.Fatal(err)
+	}
+	found, err = testCtx.store.FindDiscoverableUsersByHashes(ctx, uploader.UserID, hashes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[hashes[0]] == nil || found[hashes[0]].UserID != user.UserID {
+		t.Fatal("пользователь должен находиться по хешу номера")
+	}
+
+	// хеш клиента, ключ которого есть у всех клиентов, в базе не хранится
+	var stored int
+	rows, err := testCtx.store.Query(ctx, `select count(*) from users where phone_hash = $1`, hashes[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		if err := rows.Scan(&stored); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rows.Close()
+	if stored != 0 {
+		t.Fatal("в phone_hash должен храниться HMAC хеша серверным ключом")
+	}
+}
+
+func TestStore_PhoneEncryption(t *testing.T) {
+	oldKey := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("a", keyring.KeySize)))
+	newKey := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("b", keyring.KeySize)))
+
+	ring, err := keyring.New("k1", map[string]string{"k1": oldKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, err := phone.NewHasher("index")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCtx := prepareTestContext(t, storage.WithPhoneEncryption(ring, index))
+	ctx := context.Background()
+
+	// номер, записанный до включения шифрования
+	_, err = testCtx.store.Exec(ctx, `insert into users (name, phone_number, phone_index, phone_verified_at) values ('Пользователь1', '+79830000021', '+79830000021', now());`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = testCtx.store.Exec(ctx, `insert into pending_contacts (user_id, phone_number, phone_index, contact_name)
+		select user_id, '+79830000023', '+79830000023', 'Отложенный' from users where phone_index = '+79830000021';`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// номер в исходном виде, нормализованный миграцией только в phone_index
+	_, err = testCtx.store.Exec(ctx, `insert into users (name, phone_number, phone_index, phone_verified_at) values ('Старый', '8 (983) 000-00-02', '+79830000002', now());`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testCtx.store.FindUserByPhone(ctx, "+79830000021"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("до перешифрования индекс не совпадает, ожидалась ErrNotFound, получено %v", err)
+	}
+
+	// как при запуске сервера после миграции
+	updated, err := testCtx.store.EncryptPlaintextPhones(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated != 3 {
+		t.Fatalf("ожидалось 3 перешифрованных номера, двух пользователей и отложенного контакта, получено %d", updated)
+	}
+
+	legacy, err := testCtx.store.FindUserByPhone(ctx, "+79830000002")
+	if err != nil {
+		t.Fatalf("номер в исходном виде должен находиться по нормализованному: %v", err)
+	}
+	if legacy.Name != "Старый" {
+		t.Fatalf("найден не тот пользователь: %+v", legacy)
+	}
+
+	user, err := testCtx.store.FindUserByPhone(ctx, "+79830000021")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.PhoneNumber != "+79830000021" {
+		t.Fatalf("ожидался расшифрованный номер, получен %q", user.PhoneNumber)
+	}
+
+	created, err := testCtx.store.CreateUser(ctx, &entities.User{Name: "Пользователь2", PhoneNumber: "+79830000022"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.PhoneNumber != "+79830000022" {
+		t.Fatalf("ожидался расшифрованный номер, получен %q", created.PhoneNumber)
+	}
+
+	if err := testCtx.store.SavePendingContacts(ctx, []*entities.PendingContact{
+		{UserID: created.UserID, PhoneNumber: "+79830000024", ContactName: "Новый"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := testCtx.store.SavePhoneVerification(ctx, &entities.PhoneVerification{
+		UserID:      created.UserID,
+		PhoneNumber: created.PhoneNumber,
+		CodeHash:    []byte("hash"),
+		Salt:        []byte("salt"),
+		ExpiresAt:   time.Now().Add(time.Minute),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	verification, err := testCtx.store.GetPhoneVerification(ctx, created.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verification.PhoneNumber != created.PhoneNumber {
+		t.Fatalf("ожидался расшифрованный номер кода подтверждения, получен %q", verification.PhoneNumber)
+	}
+
+	// отложенный контакт, записанный до шифрования, находится по пересчитанному индексу
+	invited, err := testCtx.store.CreateUser(ctx, &entities.User{Name: "Пользователь3", PhoneNumber: "+79830000023"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	materialized, err := testCtx.store.MaterializePendingContacts(ctx, invited)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(materialized) != 1 || materialized[0].UserID != user.UserID || materialized[0].ContactName != "Отложенный" {
+		t.Fatalf("ожидалась связь от пользователя %d, получено %+v", user.UserID, materialized)
+	}
+
+	friends, err := testCtx.store.FindFriends(ctx, user.UserID, storage.FriendsQuery{PhoneNumber: "+79830000023"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(friends) != 1 || friends[0].RelationUserID != invited.UserID {
+		t.Fatalf("друг должен находиться по точному номеру, получено %+v", friends)
+	}
+	friends, err = testCtx.store.FindFriends(ctx, user.UserID, storage.FriendsQuery{PhoneNumber: "+79830000024"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(friends) != 0 {
+		t.Fatalf("по другому номеру друзей быть не должно, получено %+v", friends)
+	}
+
+	var plaintext int
+	rows, err := testCtx.store.Query(ctx, `select
+		(select count(*) from users where phone_number like '+7%' or phone_index like '+7%') +
+		(select count(*) from pending_contacts where phone_number like '+7%' or phone_index like '+7%') +
+		(select count(*) from phone_verifications where phone_number like '+7%')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		if err := rows.Scan(&plaintext); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rows.Close()
+	if plaintext != 0 {
+		t.Fatalf("номера не должны храниться открытым текстом, найдено %d", plaintext)
+	}
+
+	rotated, err := keyring.New("k2", map[string]string{"k1": oldKey, "k2": newKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	storage.WithPhoneEncryption(rotated, index)(testCtx.store)
+
+	updated, err = testCtx.store.ReencryptPhones(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// четыре пользователя, отложенный контакт и код подтверждения
+	if updated != 6 {
+		t.Fatalf("после смены основного ключа ожидалось 6 перешифрованных номеров, получено %d", updated)
+	}
+	if updated, _ := testCtx.store.ReencryptPhones(ctx, 0); updated != 0 {
+		t.Fatalf("повторный запуск не должен ничего менять, изменено %d", updated)
+	}
+
+	withoutOld, err := keyring.New("k2", map[string]string{"k2": newKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	storage.WithPhoneEncryption(withoutOld, index)(testCtx.store)
+	if _, err := testCtx.store.GetUser(ctx, created.UserID); err != nil {
+		t.Fatalf("после перешифрования старый ключ не нужен: %v", err)
+	}
+}
+
+func TestStore_ExportAndEraseUser(t *testing.T) {
+	testCtx := prepareTestContext(t)
+	ctx := context.Background()
+
+	users := make([]*entities.User, 0, 2)
+	for _, phone := range []string{"+79830000031", "+79830000032"} {
+		user, err := testCtx.store.CreateUser(ctx, &entities.User{Name: "Пользователь", PhoneNumber: phone})
+		if err != nil {
+			t.Fatal(err)
+		}
+		users = append(users, user)
+	}
+	a, b := users[0], users[1]
+
+	if _, err := testCtx.store.SaveRelations(ctx, entities.RelationList{
+		{UserID: a.UserID, RelationUserID: b.UserID, ContactName: "Друг"},
+		{UserID: b.UserID, RelationUserID: a.UserID, ContactName: "Тоже друг"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := testCtx.store.SavePendingContacts(ctx, []*entities.PendingContact{{UserID: a.UserID, PhoneNumber: "+79830000033", ContactName: "Новый"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	export, err := testCtx.store.ExportUser(ctx, a.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if export.User.PhoneNumber != a.PhoneNumber || len(export.Relations) != 1 || len(export.IncomingRelations) != 1 || len(export.PendingContacts) != 1 {
+		t.Fatalf("неполная выгрузка: %+v", export)
+	}
+
+	err = testCtx.store.WithinTx(ctx, pgx.TxOptions{}, func(ctx context.Context) error {
+		return testCtx.store.EraseUser(ctx, a.UserID)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := testCtx.store.GetUser(ctx, a.UserID); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("после удаления ожидалась ErrNotFound, получено %v", err)
+	}
+	if _, err := testCtx.store.ExportUser(ctx, a.UserID); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("после удаления выгрузка должна вернуть ErrNotFound, получено %v", err)
+	}
+	if err := testCtx.store.EraseUser(ctx, a.UserID); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("повторное удаление должно вернуть ErrNotFound, получено %v", err)
+	}
+
+	followers, err := testCtx.store.FindFollowers(ctx, b.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	friends, err := testCtx.store.FindFriends(ctx, b.UserID, storage.FriendsQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(followers) != 0 || len(friends) != 0 {
+		t.Fatal("связи с удаленным пользователем должны быть удалены в обе стороны")
+	}
+}
+
+func TestStore_AuditEvents(t *testing.T) {
+	testCtx := prepareTestContext(t)
+	ctx := context.Background()
+
+	actor := 1
+	events := []*entities.AuditEvent{
+		{Action: entities.AuditSaveContacts, ActorUserID: &actor, TargetUserID: 1, Count: 3, RequestID: "req-1"},
+		{Action: entities.AuditFindFriends, ActorUserID: &actor, TargetUserID: 1, Count: 2, RequestID: "req-2"},
+		{Action: entities.AuditGetName, ActorSubject: "ops@example.com", ActorRole: "admin", TargetUserID: 2, Count: 1},
+	}
+	for _, event := range events {
+		if err := testCtx.store.SaveAuditEvent(ctx, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	found, err := testCtx.store.FindAuditEvents(ctx, storage.AuditQuery{ActorUserID: actor, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0].Action != entities.AuditFindFriends || found[0].RequestID != "req-2" {
+		t.Fatalf("ожидалось последнее событие пользователя, получено %+v", found)
+	}
+
+	found, err = testCtx.store.FindAuditEvents(ctx, storage.AuditQuery{ActorUserID: actor, Cursor: found[0].EventID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0].Action != entities.AuditSaveContacts || found[0].Count != 3 {
+		t.Fatalf("ожидалось первое событие пользователя, получено %+v", found)
+	}
+
+	found, err = testCtx.store.FindAuditEvents(ctx, storage.AuditQuery{ActorSubject: "ops@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0].ActorUserID != nil || found[0].ActorRole != "admin" {
+		t.Fatalf("ожидалось событие администратора, получено %+v", found)
+	}
+
+	found, err = testCtx.store.FindAuditEvents(ctx, storage.AuditQuery{To: time.Now().Add(-time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 0 {
+		t.Fatal("событий до указанного времени быть не должно")
+	}
+
+	// в выгрузке данных пользователя администратор виден только по роли
+	exported, err := testCtx.store.FindUserAuditEvents(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exported) != 1 || exported[0].ActorSubject != "" || exported[0].ActorRole != "admin" || exported[0].ActorUserID != nil {
+		t.Fatalf("ожидалось обезличенное событие администратора, получено %+v", exported)
+	}
+	exported, err = testCtx.store.FindUserAuditEvents(ctx, actor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exported) != 2 || exported[0].ActorUserID == nil || exported[0].RequestID != "req-1" {
+		t.Fatalf("собственные события должны выгружаться полностью, получено %+v", exported)
+	}
+
+	if _, err := testCtx.store.Exec(ctx, `update audit_events set item_count = 0`); err == nil {
+		t.Fatal("изменение журнала аудита должно быть запрещено")
+	}
+
+	pruned, err := testCtx.store.PruneAuditEvents(ctx, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pruned != len(events) {
+		t.Fatalf("ожидалось удаление %d событий, удалено %d", len(events), pruned)
+	}
+}